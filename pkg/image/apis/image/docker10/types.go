@@ -0,0 +1,171 @@
+// Package docker10 holds the golang types for the Docker V1 and V2 schema2
+// image configuration formats, along with helpers for converting between
+// them and the OCI image config format.
+package docker10
+
+import (
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// DockerConfig is the list of configuration options used to create a container.
+type DockerConfig struct {
+	Hostname        string              `json:"Hostname,omitempty"`
+	Domainname      string              `json:"Domainname,omitempty"`
+	User            string              `json:"User,omitempty"`
+	Memory          int64               `json:"Memory,omitempty"`
+	MemorySwap      int64               `json:"MemorySwap,omitempty"`
+	CPUShares       int64               `json:"CpuShares,omitempty"`
+	CPUSet          string              `json:"Cpuset,omitempty"`
+	AttachStdin     bool                `json:"AttachStdin,omitempty"`
+	AttachStdout    bool                `json:"AttachStdout,omitempty"`
+	AttachStderr    bool                `json:"AttachStderr,omitempty"`
+	PortSpecs       []string            `json:"PortSpecs,omitempty"`
+	ExposedPorts    map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Tty             bool                `json:"Tty,omitempty"`
+	OpenStdin       bool                `json:"OpenStdin,omitempty"`
+	StdinOnce       bool                `json:"StdinOnce,omitempty"`
+	Env             []string            `json:"Env,omitempty"`
+	Cmd             []string            `json:"Cmd,omitempty"`
+	Image           string              `json:"Image,omitempty"`
+	Volumes         map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir      string              `json:"WorkingDir,omitempty"`
+	Entrypoint      []string            `json:"Entrypoint,omitempty"`
+	NetworkDisabled bool                `json:"NetworkDisabled,omitempty"`
+	Labels          map[string]string   `json:"Labels,omitempty"`
+}
+
+// DockerConfigHistory stores build time created image history.
+type DockerConfigHistory struct {
+	Created    time.Time `json:"created"`
+	Author     string    `json:"author,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// DockerConfigRootFS describes images root filesystem.
+type DockerConfigRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids,omitempty"`
+}
+
+// DockerImageConfig stores the image configuration in the v1/v2 schema2 format.
+type DockerImageConfig struct {
+	ID              string                `json:"id,omitempty"`
+	Parent          string                `json:"parent,omitempty"`
+	Comment         string                `json:"comment,omitempty"`
+	Created         time.Time             `json:"created"`
+	Container       string                `json:"container,omitempty"`
+	ContainerConfig DockerConfig          `json:"container_config,omitempty"`
+	DockerVersion   string                `json:"docker_version,omitempty"`
+	Author          string                `json:"author,omitempty"`
+	Config          *DockerConfig         `json:"config,omitempty"`
+	Architecture    string                `json:"architecture,omitempty"`
+	OS              string                `json:"os,omitempty"`
+	Size            int64                 `json:"Size,omitempty"`
+	RootFS          *DockerConfigRootFS   `json:"rootfs,omitempty"`
+	History         []DockerConfigHistory `json:"history,omitempty"`
+}
+
+// DockerV1CompatibilityImage is the JSON structure stored in the schema1
+// manifest history entries.
+type DockerV1CompatibilityImage struct {
+	ID              string        `json:"id,omitempty"`
+	Parent          string        `json:"parent,omitempty"`
+	Comment         string        `json:"comment,omitempty"`
+	Created         time.Time     `json:"created"`
+	Container       string        `json:"container,omitempty"`
+	ContainerConfig DockerConfig  `json:"container_config,omitempty"`
+	DockerVersion   string        `json:"docker_version,omitempty"`
+	Author          string        `json:"author,omitempty"`
+	Config          *DockerConfig `json:"config,omitempty"`
+	Architecture    string        `json:"architecture,omitempty"`
+	OS              string        `json:"os,omitempty"`
+	Size            int64         `json:"Size,omitempty"`
+	ThrowAway       bool          `json:"throwaway,omitempty"`
+}
+
+// Convert_DockerV1CompatibilityImage_to_DockerImageConfig converts a schema1
+// v1Compatibility history entry into the common DockerImageConfig type used
+// throughout this package.
+func Convert_DockerV1CompatibilityImage_to_DockerImageConfig(in *DockerV1CompatibilityImage, out *DockerImageConfig) error {
+	out.ID = in.ID
+	out.Parent = in.Parent
+	out.Comment = in.Comment
+	out.Created = in.Created
+	out.Container = in.Container
+	out.ContainerConfig = in.ContainerConfig
+	out.DockerVersion = in.DockerVersion
+	out.Author = in.Author
+	out.Config = in.Config
+	out.Architecture = in.Architecture
+	out.OS = in.OS
+	out.Size = in.Size
+	return nil
+}
+
+// Convert_OCI_Image_Config_to_DockerImageConfig converts an OCI image config
+// (the payload referenced by an OCI manifest's Config descriptor) into the
+// common DockerImageConfig type so that append can treat OCI and Docker
+// schema2 sources identically.
+func Convert_OCI_Image_Config_to_DockerImageConfig(in *ocispec.Image, out *DockerImageConfig) error {
+	out.Created = time.Time{}
+	if in.Created != nil {
+		out.Created = *in.Created
+	}
+	out.Author = in.Author
+	out.Architecture = in.Architecture
+	out.OS = in.OS
+	out.Config = &DockerConfig{
+		User:         in.Config.User,
+		ExposedPorts: convertExposedPorts(in.Config.ExposedPorts),
+		Env:          in.Config.Env,
+		Entrypoint:   in.Config.Entrypoint,
+		Cmd:          in.Config.Cmd,
+		Volumes:      convertVolumes(in.Config.Volumes),
+		WorkingDir:   in.Config.WorkingDir,
+		Labels:       in.Config.Labels,
+	}
+	out.RootFS = &DockerConfigRootFS{Type: "layers"}
+	for _, d := range in.RootFS.DiffIDs {
+		out.RootFS.DiffIDs = append(out.RootFS.DiffIDs, d.String())
+	}
+	for _, h := range in.History {
+		created := time.Time{}
+		if h.Created != nil {
+			created = *h.Created
+		}
+		out.History = append(out.History, DockerConfigHistory{
+			Created:    created,
+			Author:     h.Author,
+			CreatedBy:  h.CreatedBy,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		})
+	}
+	return nil
+}
+
+func convertExposedPorts(in map[string]struct{}) map[string]struct{} {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]struct{}, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func convertVolumes(in map[string]struct{}) map[string]struct{} {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]struct{}, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
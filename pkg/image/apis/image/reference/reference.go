@@ -0,0 +1,161 @@
+// Package reference parses Docker image references of the form
+// [registry/]repository[:tag|@id] used throughout the image commands.
+package reference
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DockerImageReference points to a Docker image.
+type DockerImageReference struct {
+	// Transport is the scheme selected by a "transport:" or "transport://" prefix:
+	// "docker" (a registry, the default), "docker-archive", "oci", "oci-archive", or "dir".
+	Transport string
+
+	// Location is the transport-specific locator for non-registry transports (a file or
+	// directory path). It is empty for the "docker" transport, which instead populates
+	// Registry/Namespace/Name/Tag/ID below.
+	Location string
+
+	Registry  string
+	Namespace string
+	Name      string
+	Tag       string
+	ID        string
+}
+
+const (
+	// TransportDocker is a registry reference, e.g. "docker://registry/repo:tag" or the bare
+	// "registry/repo:tag" shorthand.
+	TransportDocker = "docker"
+	// TransportDockerArchive is a "docker save" tarball on disk.
+	TransportDockerArchive = "docker-archive"
+	// TransportOCI is a directory laid out per the OCI Image Layout spec.
+	TransportOCI = "oci"
+	// TransportOCIArchive is a tar archive of an OCI image layout directory.
+	TransportOCIArchive = "oci-archive"
+	// TransportDir is a directory holding an unpacked manifest and its blobs as individual
+	// files, one layer per file, matching the containers/image "dir:" transport.
+	TransportDir = "dir"
+)
+
+// DockerClientDefaults sets the default values used by the Docker client for fields not set on a reference.
+func (r DockerImageReference) DockerClientDefaults() DockerImageReference {
+	if len(r.Transport) > 0 && r.Transport != TransportDocker {
+		return r
+	}
+	if len(r.Registry) == 0 {
+		r.Registry = "docker.io"
+	}
+	if len(r.Namespace) == 0 && !strings.Contains(r.Registry, ".") {
+		r.Namespace = "library"
+	}
+	if len(r.Tag) == 0 && len(r.ID) == 0 {
+		r.Tag = "latest"
+	}
+	return r
+}
+
+// RegistryURL returns the base URL of the registry for this reference.
+func (r DockerImageReference) RegistryURL() string {
+	return r.Registry
+}
+
+// RepositoryName returns the registry relative name for the reference.
+func (r DockerImageReference) RepositoryName() string {
+	if len(r.Namespace) == 0 {
+		return r.Name
+	}
+	return fmt.Sprintf("%s/%s", r.Namespace, r.Name)
+}
+
+// String converts a DockerImageReference to a string.
+func (r DockerImageReference) String() string {
+	if len(r.Transport) > 0 && r.Transport != TransportDocker {
+		out := r.Transport + ":" + r.Location
+		if len(r.Tag) > 0 {
+			out += ":" + r.Tag
+		}
+		return out
+	}
+	var out string
+	if len(r.Registry) > 0 {
+		out += r.Registry + "/"
+	}
+	out += r.RepositoryName()
+	switch {
+	case len(r.Tag) > 0:
+		out += ":" + r.Tag
+	case len(r.ID) > 0:
+		out += "@" + r.ID
+	}
+	return out
+}
+
+// nonRegistryTransports maps the "transport:" prefixes accepted by --from/--to,
+// other than "docker://", to their TransportXxx constant.
+var nonRegistryTransports = map[string]string{
+	"docker-archive:": TransportDockerArchive,
+	"oci-archive:":    TransportOCIArchive,
+	"oci:":            TransportOCI,
+	"dir:":            TransportDir,
+}
+
+// Parse parses a Docker image reference of the form [registry/]repository[:tag|@id],
+// or a transport-qualified reference of the form "transport:location[:tag]" — one of
+// "docker://", "docker-archive:", "oci:", "oci-archive:", or "dir:".
+func Parse(spec string) (DockerImageReference, error) {
+	var ref DockerImageReference
+	if len(spec) == 0 {
+		return ref, fmt.Errorf("the image reference must not be empty")
+	}
+
+	if strings.HasPrefix(spec, "docker://") {
+		spec = strings.TrimPrefix(spec, "docker://")
+	} else {
+		for prefix, transport := range nonRegistryTransports {
+			if !strings.HasPrefix(spec, prefix) {
+				continue
+			}
+			location := strings.TrimPrefix(spec, prefix)
+			if len(location) == 0 {
+				return ref, fmt.Errorf("invalid image reference: %s: %s requires a location", spec, prefix)
+			}
+			ref.Transport = transport
+			ref.Location = location
+			if colon := strings.LastIndex(location, ":"); colon != -1 {
+				ref.Location = location[:colon]
+				ref.Tag = location[colon+1:]
+			}
+			return ref, nil
+		}
+	}
+	ref.Transport = TransportDocker
+
+	name := spec
+	if at := strings.LastIndex(name, "@"); at != -1 {
+		ref.ID = name[at+1:]
+		name = name[:at]
+	} else if colon := strings.LastIndex(name, ":"); colon != -1 && !strings.Contains(name[colon:], "/") {
+		ref.Tag = name[colon+1:]
+		name = name[:colon]
+	}
+
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 1:
+		ref.Name = parts[0]
+	case len(parts) == 2:
+		ref.Namespace = parts[0]
+		ref.Name = parts[1]
+	default:
+		ref.Registry = parts[0]
+		ref.Namespace = strings.Join(parts[1:len(parts)-1], "/")
+		ref.Name = parts[len(parts)-1]
+	}
+	if len(ref.Name) == 0 {
+		return ref, fmt.Errorf("invalid image reference: %s", spec)
+	}
+	return ref, nil
+}
@@ -0,0 +1,96 @@
+package reference
+
+import "testing"
+
+func TestParseNonRegistryTransports(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected DockerImageReference
+	}{
+		{
+			spec:     "docker-archive:/tmp/image.tar",
+			expected: DockerImageReference{Transport: TransportDockerArchive, Location: "/tmp/image.tar"},
+		},
+		{
+			spec:     "docker-archive:/tmp/image.tar:latest",
+			expected: DockerImageReference{Transport: TransportDockerArchive, Location: "/tmp/image.tar", Tag: "latest"},
+		},
+		{
+			spec:     "oci:/tmp/layout",
+			expected: DockerImageReference{Transport: TransportOCI, Location: "/tmp/layout"},
+		},
+		{
+			spec:     "oci:/tmp/layout:v1",
+			expected: DockerImageReference{Transport: TransportOCI, Location: "/tmp/layout", Tag: "v1"},
+		},
+		{
+			spec:     "oci-archive:/tmp/layout.tar",
+			expected: DockerImageReference{Transport: TransportOCIArchive, Location: "/tmp/layout.tar"},
+		},
+		{
+			spec:     "dir:/tmp/unpacked",
+			expected: DockerImageReference{Transport: TransportDir, Location: "/tmp/unpacked"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			ref, err := Parse(test.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", test.spec, err)
+			}
+			if ref != test.expected {
+				t.Fatalf("Parse(%q) = %#v, expected %#v", test.spec, ref, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseNonRegistryTransportsRequireLocation(t *testing.T) {
+	for _, spec := range []string{"docker-archive:", "oci:", "oci-archive:", "dir:"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected an error, got none", spec)
+		}
+	}
+}
+
+func TestStringRoundTripsNonRegistryTransports(t *testing.T) {
+	tests := []string{
+		"docker-archive:/tmp/image.tar",
+		"docker-archive:/tmp/image.tar:latest",
+		"oci:/tmp/layout",
+		"oci:/tmp/layout:v1",
+		"oci-archive:/tmp/layout.tar",
+		"dir:/tmp/unpacked",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			ref, err := Parse(spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", spec, err)
+			}
+			if got := ref.String(); got != spec {
+				t.Fatalf("String() = %q, expected %q", got, spec)
+			}
+		})
+	}
+}
+
+func TestParseDockerReference(t *testing.T) {
+	ref, err := Parse("docker://registry.example.com/foo/bar:v1")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	expected := DockerImageReference{
+		Transport: TransportDocker,
+		Registry:  "registry.example.com",
+		Namespace: "foo",
+		Name:      "bar",
+		Tag:       "v1",
+	}
+	if ref != expected {
+		t.Fatalf("Parse() = %#v, expected %#v", ref, expected)
+	}
+	if got := ref.String(); got != "registry.example.com/foo/bar:v1" {
+		t.Fatalf("String() = %q, expected %q", got, "registry.example.com/foo/bar:v1")
+	}
+}
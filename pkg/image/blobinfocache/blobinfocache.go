@@ -0,0 +1,129 @@
+// Package blobinfocache persists what "oc image append" has already learned
+// about layer blobs across invocations, so repeat runs against the same
+// source image do not re-stream layers whose content ID is already known or
+// re-upload layers already known to exist at the destination.
+package blobinfocache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Entry records what is known about a single source layer blob.
+type Entry struct {
+	// DstDigest is the digest the blob was last uploaded under, which differs
+	// from the source digest when the layer was recompressed on push.
+	DstDigest digest.Digest `json:"dstDigest,omitempty"`
+	// Size is the size in bytes of the blob as uploaded (DstDigest's size, not
+	// necessarily the source's).
+	Size int64 `json:"size,omitempty"`
+	// Compression is the compression algorithm the blob was uploaded with
+	// ("gzip" or "zstd"), matching the --compression flag's values.
+	Compression string `json:"compression,omitempty"`
+	// DiffID is the digest of the layer's decompressed, decrypted contents.
+	DiffID string `json:"diffID,omitempty"`
+	// PendingUploadID is the destination blob writer's ID() for an upload
+	// that was left in progress, so it can be resumed instead of restarted.
+	PendingUploadID string `json:"pendingUploadID,omitempty"`
+}
+
+// Cache is a persistent, on-disk cache of Entry values keyed by source blob
+// digest. It is safe for concurrent use.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[digest.Digest]Entry
+}
+
+// Open loads the cache from its well-known location under
+// $XDG_CACHE_HOME/origin/append/ (or ~/.cache/origin/append/ if
+// XDG_CACHE_HOME is unset), creating an empty cache if none exists yet.
+func Open() (*Cache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		path:    filepath.Join(dir, "blobinfocache.json"),
+		entries: make(map[digest.Digest]Entry),
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func cacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if len(base) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	dir := filepath.Join(base, "origin", "append")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Lookup returns what is known about the blob srcDigest decompresses/decrypts
+// to, if anything.
+func (c *Cache) Lookup(srcDigest digest.Digest) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[srcDigest]
+	return e, ok
+}
+
+// Record stores what was learned about srcDigest and persists the cache to
+// disk. A failure to persist is returned but does not roll back the
+// in-memory update, since losing the on-disk cache only costs performance on
+// the next run, not correctness. The marshal and write happen under the same
+// lock as the map mutation so concurrent callers (append.go uploads layers
+// with up to --max-per-registry goroutines) can't race two snapshots to disk
+// and have the fuller one lost to whichever rename lands first.
+func (c *Cache) Record(srcDigest digest.Digest, e Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[srcDigest] = e
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path and renames it into place, so a process killed mid-write can never
+// leave a corrupt cache behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".blobinfocache-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+// Package add contains helpers shared by commands that append layers to an
+// image, such as computing diffIDs while streaming a layer and assembling the
+// resulting manifest and config blobs.
+package add
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema2"
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/openshift/origin/pkg/image/apis/image/docker10"
+	"github.com/openshift/origin/pkg/image/dockerlayer"
+)
+
+// NewEmptyConfig returns a DockerImageConfig representing an empty scratch image.
+func NewEmptyConfig() *docker10.DockerImageConfig {
+	return &docker10.DockerImageConfig{
+		RootFS: &docker10.DockerConfigRootFS{Type: "layers"},
+	}
+}
+
+// AddScratchLayerToConfig registers the well known empty layer against config and
+// returns its descriptor.
+func AddScratchLayerToConfig(config *docker10.DockerImageConfig) distribution.Descriptor {
+	desc := distribution.Descriptor{
+		MediaType: schema2.MediaTypeLayer,
+		Digest:    dockerlayer.GzippedEmptyLayerDigest,
+		Size:      int64(len(dockerlayer.GzippedEmptyLayer)),
+	}
+	AddLayerToConfig(config, desc, string(dockerlayer.GzippedEmptyLayerDigest))
+	return desc
+}
+
+// AddLayerToConfig updates config to describe an additional layer identified by
+// desc with the provided diffID. An empty diffID indicates the caller will
+// backfill RootFS.DiffIDs once it is known.
+func AddLayerToConfig(config *docker10.DockerImageConfig, desc distribution.Descriptor, diffID string) {
+	if config.RootFS == nil {
+		config.RootFS = &docker10.DockerConfigRootFS{Type: "layers"}
+	}
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+}
+
+// DigestCopy copies src to dst (if dst is non-nil) while simultaneously
+// calculating the uncompressed tar digest (diffID), the digest of the bytes as
+// read from src (the blob digest), and the modification time recorded in the
+// gzip header of a gzip-compressed layer, if any (nil for zstd and
+// uncompressed layers). n is the number of (compressed) bytes read
+// from src, matching the size that will be recorded in the blob's
+// descriptor. The source's compression is autodetected (gzip, zstd, or none)
+// so the diffID can be calculated regardless of which compression was used
+// to store the layer.
+func DigestCopy(dst io.ReaderFrom, src io.Reader) (layerDigest digest.Digest, blobDigest digest.Digest, modTime *time.Time, n int64, err error) {
+	if dst == nil {
+		dst = ioutil.Discard.(io.ReaderFrom)
+	}
+	blobDigester := digest.Canonical.Digester()
+	tr := io.TeeReader(src, blobDigester.Hash())
+
+	compression, peeked, err := dockerlayer.DetectCompression(tr)
+	if err != nil {
+		return "", "", nil, 0, err
+	}
+
+	// peeked yields the same bytes dst must upload; tee them into a pipe so a
+	// decompressor can independently derive the diffID without disturbing
+	// what dst receives.
+	pr, pw := io.Pipe()
+	decompressErr := make(chan error, 1)
+	layerDigester := digest.Canonical.Digester()
+	go func() {
+		decompressed, err := dockerlayer.NewDecompressingReader(compression, pr)
+		if err != nil {
+			pr.CloseWithError(err)
+			decompressErr <- err
+			return
+		}
+		if gr, ok := decompressed.(*gzip.Reader); ok && !gr.Header.ModTime.IsZero() {
+			t := gr.Header.ModTime
+			modTime = &t
+		}
+		_, err = io.Copy(layerDigester.Hash(), decompressed)
+		decompressed.Close()
+		pr.Close()
+		decompressErr <- err
+	}()
+
+	n, err = dst.ReadFrom(io.TeeReader(peeked, pw))
+	pw.Close()
+	if err != nil {
+		<-decompressErr
+		return "", "", nil, n, err
+	}
+	if err := <-decompressErr; err != nil {
+		return "", "", nil, n, err
+	}
+	return layerDigester.Digest(), blobDigester.Digest(), modTime, n, nil
+}
+
+// UploadSchema2Config assembles and returns the schema2 manifest referencing
+// layers. The manifest builder uploads the config blob itself as part of
+// Build, so callers do not need to blobs.Put it beforehand.
+func UploadSchema2Config(ctx context.Context, blobs distribution.BlobService, config *docker10.DockerImageConfig, layers []distribution.Descriptor) (distribution.Manifest, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	b := schema2.NewManifestBuilder(blobs, configJSON)
+	for _, layer := range layers {
+		if err := b.AppendReference(layer); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build(ctx)
+}
+
+// UploadOCIManifest assembles and returns the OCI image manifest referencing
+// layers. It mirrors UploadSchema2Config but produces the OCI media types
+// used by --output-format=oci.
+func UploadOCIManifest(ctx context.Context, blobs distribution.BlobService, config *docker10.DockerImageConfig, layers []distribution.Descriptor) (distribution.Manifest, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	b := ocischema.NewManifestBuilder(blobs, configJSON, nil)
+	for _, layer := range layers {
+		layer.MediaType = OCILayerMediaType(layer.MediaType)
+		if err := b.AppendReference(layer); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build(ctx)
+}
+
+// OCILayerMediaType maps a Docker schema2 layer media type to its OCI
+// equivalent, leaving already-OCI or unrecognized media types unchanged.
+func OCILayerMediaType(mediaType string) string {
+	switch mediaType {
+	case schema2.MediaTypeLayer:
+		return ocispecLayerMediaType
+	case schema2.MediaTypeForeignLayer:
+		return ocispecForeignLayerMediaType
+	default:
+		return mediaType
+	}
+}
+
+const (
+	ocispecLayerMediaType        = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ocispecForeignLayerMediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+)
@@ -0,0 +1,24 @@
+package add
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func TestOCILayerMediaType(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{schema2.MediaTypeLayer, ocispecLayerMediaType},
+		{schema2.MediaTypeForeignLayer, ocispecForeignLayerMediaType},
+		{ocispecLayerMediaType, ocispecLayerMediaType},
+		{"application/vnd.oci.image.layer.v1.tar+zstd", "application/vnd.oci.image.layer.v1.tar+zstd"},
+	}
+	for _, test := range tests {
+		if got := OCILayerMediaType(test.in); got != test.expected {
+			t.Errorf("OCILayerMediaType(%q) = %q, expected %q", test.in, got, test.expected)
+		}
+	}
+}
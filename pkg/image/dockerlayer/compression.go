@@ -0,0 +1,154 @@
+package dockerlayer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the compression algorithm used by a layer blob.
+type Compression string
+
+const (
+	// None indicates the blob is an uncompressed tar stream.
+	None Compression = ""
+	// Gzip indicates the blob is a gzip-compressed tar stream.
+	Gzip Compression = "gzip"
+	// Zstd indicates the blob is a zstd-compressed tar stream.
+	Zstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DetectCompression peeks at the start of r to determine which, if any,
+// compression algorithm was used, and returns a reader that replays the
+// peeked bytes so callers can still read the full stream from the beginning.
+func DetectCompression(r io.Reader) (Compression, io.Reader, error) {
+	br := bufio.NewReaderSize(r, 4)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return None, br, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		return Zstd, br, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return Gzip, br, nil
+	default:
+		return None, br, nil
+	}
+}
+
+// MediaTypeForCompression returns the layer media type that corresponds to
+// compression (the OCI zstd layer types for zstd, the Docker schema2 layer
+// types otherwise), preferring the foreign layer variant when foreign is
+// true.
+func MediaTypeForCompression(compression Compression, foreign bool) string {
+	switch compression {
+	case Zstd:
+		if foreign {
+			return "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
+		}
+		return "application/vnd.oci.image.layer.v1.tar+zstd"
+	case Gzip:
+		if foreign {
+			return schema2.MediaTypeForeignLayer
+		}
+		return schema2.MediaTypeLayer
+	default:
+		if foreign {
+			return schema2.MediaTypeForeignLayer
+		}
+		return schema2.MediaTypeLayer
+	}
+}
+
+// NewDecompressingReader wraps r with a reader that yields the uncompressed
+// tar stream for the given compression. compression must have been
+// determined by DetectCompression (or known by other means) ahead of time.
+func NewDecompressingReader(compression Compression, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case None:
+		return ioutilNopCloser(r), nil
+	default:
+		return nil, fmt.Errorf("unrecognized layer compression %q", compression)
+	}
+}
+
+// NewCompressingWriter wraps w with a writer that compresses the tar stream
+// written to it using the given compression. Callers must Close the returned
+// writer to flush trailing compressed data.
+func NewCompressingWriter(compression Compression, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unrecognized layer compression %q", compression)
+	}
+}
+
+// Recompress returns a reader that yields r's contents recompressed with
+// target, autodetecting r's current compression. If r is already compressed
+// with target, it is returned unmodified.
+func Recompress(target Compression, r io.Reader) (io.Reader, error) {
+	compression, peeked, err := DetectCompression(r)
+	if err != nil {
+		return nil, err
+	}
+	if compression == target {
+		return peeked, nil
+	}
+
+	decompressed, err := NewDecompressingReader(compression, peeked)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		cw, err := NewCompressingWriter(target, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			decompressed.Close()
+			return
+		}
+		_, err = io.Copy(cw, decompressed)
+		decompressed.Close()
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+func ioutilNopCloser(r io.Reader) io.ReadCloser {
+	if rc, ok := r.(io.ReadCloser); ok {
+		return rc
+	}
+	return nopReadCloser{r}
+}
+
+type nopReadCloser struct {
+	io.Reader
+}
+
+func (nopReadCloser) Close() error { return nil }
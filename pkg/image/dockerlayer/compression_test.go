@@ -0,0 +1,122 @@
+package dockerlayer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDetectCompressionAndDecompress(t *testing.T) {
+	const content = "hello layer contents, repeated for good measure hello layer contents"
+
+	for _, compression := range []Compression{None, Gzip, Zstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			var buf bytes.Buffer
+			if compression == None {
+				buf.WriteString(content)
+			} else {
+				w, err := NewCompressingWriter(compression, &buf)
+				if err != nil {
+					t.Fatalf("NewCompressingWriter: %v", err)
+				}
+				if _, err := w.Write([]byte(content)); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			detected, peeked, err := DetectCompression(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("DetectCompression: %v", err)
+			}
+			if detected != compression {
+				t.Fatalf("DetectCompression() = %q, expected %q", detected, compression)
+			}
+
+			decompressed, err := NewDecompressingReader(detected, peeked)
+			if err != nil {
+				t.Fatalf("NewDecompressingReader: %v", err)
+			}
+			got, err := ioutil.ReadAll(decompressed)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if string(got) != content {
+				t.Fatalf("decompressed content = %q, expected %q", got, content)
+			}
+		})
+	}
+}
+
+func TestRecompress(t *testing.T) {
+	const content = "hello layer contents, repeated for good measure hello layer contents"
+
+	var gzipped bytes.Buffer
+	w, err := NewCompressingWriter(Gzip, &gzipped)
+	if err != nil {
+		t.Fatalf("NewCompressingWriter: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := Recompress(Zstd, bytes.NewReader(gzipped.Bytes()))
+	if err != nil {
+		t.Fatalf("Recompress: %v", err)
+	}
+	recompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	detected, peeked, err := DetectCompression(bytes.NewReader(recompressed))
+	if err != nil {
+		t.Fatalf("DetectCompression: %v", err)
+	}
+	if detected != Zstd {
+		t.Fatalf("Recompress produced compression %q, expected %q", detected, Zstd)
+	}
+	decompressed, err := NewDecompressingReader(detected, peeked)
+	if err != nil {
+		t.Fatalf("NewDecompressingReader: %v", err)
+	}
+	got, err := ioutil.ReadAll(decompressed)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("recompressed content = %q, expected %q", got, content)
+	}
+}
+
+func TestRecompressNoOpWhenAlreadyTarget(t *testing.T) {
+	const content = "unchanged"
+	var gzipped bytes.Buffer
+	w, err := NewCompressingWriter(Gzip, &gzipped)
+	if err != nil {
+		t.Fatalf("NewCompressingWriter: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := Recompress(Gzip, bytes.NewReader(gzipped.Bytes()))
+	if err != nil {
+		t.Fatalf("Recompress: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, gzipped.Bytes()) {
+		t.Fatalf("Recompress to the same target should return the source unmodified")
+	}
+}
@@ -0,0 +1,317 @@
+// Package encrypt implements the layer encryption envelope used by
+// "oc image append --encryption-key/--encryption-recipient/--decryption-key",
+// mirroring the ocicrypt conventions adopted across the containers/image
+// ecosystem: an encrypted layer is wrapped in an application/…+encrypted
+// media type and carries the information needed to unwrap its per-layer key
+// as annotations on the descriptor rather than inside the layer itself, so
+// registries and mirroring tools can copy ciphertext without ever holding a
+// key.
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/distribution"
+)
+
+const (
+	// annotationKeysPrefix namespaces the per-recipient wrapped-key
+	// annotations, following the org.opencontainers.image.enc.keys.<scheme>
+	// convention used by ocicrypt.
+	annotationKeysPrefix = "org.opencontainers.image.enc.keys."
+	// AnnotationPubOpts carries the non-secret parameters (cipher, digest)
+	// needed to unwrap the layer once a recipient's key is recovered.
+	AnnotationPubOpts = "org.opencontainers.image.enc.pubopts"
+
+	mediaTypeSuffix = "+encrypted"
+)
+
+// Recipient identifies a party an encrypted layer's key should be wrapped
+// for. Scheme is one of "jwe", "pgp", or "pkcs7", matching the prefixes
+// recognized by --encryption-recipient, though only "jwe" is implemented by
+// wrapKey today; ParseRecipients rejects the others up front.
+type Recipient struct {
+	Scheme string
+	Value  string
+}
+
+// EncryptConfig carries the recipients a layer should be encrypted for.
+type EncryptConfig struct {
+	Recipients []Recipient
+}
+
+// DecryptConfig carries the private material used to unwrap a layer
+// previously encrypted for one of our recipients.
+type DecryptConfig struct {
+	// Keys holds raw PEM-encoded private keys, one per --decryption-key flag.
+	Keys [][]byte
+}
+
+// ParseRecipients turns the values of repeated --encryption-recipient flags
+// (of the form "<scheme>:<value>", e.g. "jwe:/path/to/pub.pem") into an
+// EncryptConfig. "pgp" and "pkcs7" recipients are recognized but rejected
+// here with an explicit "not yet implemented" error, since wrapKey has no
+// way to honor them; better to fail before any layer is touched than deep
+// inside the upload loop on the first layer.
+func ParseRecipients(specs []string) (*EncryptConfig, error) {
+	ec := &EncryptConfig{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --encryption-recipient %q: must be of the form <scheme>:<value>", spec)
+		}
+		switch parts[0] {
+		case "jwe":
+		case "pgp", "pkcs7":
+			return nil, fmt.Errorf("invalid --encryption-recipient %q: recipient scheme %q is not yet implemented", spec, parts[0])
+		default:
+			return nil, fmt.Errorf("invalid --encryption-recipient %q: unknown scheme %q (expected jwe)", spec, parts[0])
+		}
+		ec.Recipients = append(ec.Recipients, Recipient{Scheme: parts[0], Value: parts[1]})
+	}
+	return ec, nil
+}
+
+// ParseDecryptionKeys reads the files named by repeated --decryption-key
+// flags into a DecryptConfig.
+func ParseDecryptionKeys(paths []string) (*DecryptConfig, error) {
+	dc := &DecryptConfig{}
+	for _, path := range paths {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read --decryption-key %s: %v", path, err)
+		}
+		dc.Keys = append(dc.Keys, key)
+	}
+	return dc, nil
+}
+
+// MediaType returns the encrypted variant of mediaType, matching the
+// "<type>+encrypted" suffix convention.
+func MediaType(mediaType string) string {
+	if IsEncrypted(mediaType) {
+		return mediaType
+	}
+	return mediaType + mediaTypeSuffix
+}
+
+// IsEncrypted reports whether mediaType identifies an encrypted layer.
+func IsEncrypted(mediaType string) bool {
+	return strings.HasSuffix(mediaType, mediaTypeSuffix)
+}
+
+// BaseMediaType strips the encrypted suffix from mediaType, if present.
+func BaseMediaType(mediaType string) string {
+	return strings.TrimSuffix(mediaType, mediaTypeSuffix)
+}
+
+// EncryptLayer wraps r in AES-256-GCM encryption using a freshly generated
+// per-layer key, seals that key for each of ec's recipients, and returns the
+// ciphertext reader along with the descriptor annotations a caller should
+// attach to the uploaded blob's descriptor. The caller is responsible for
+// updating the descriptor's MediaType (see MediaType) and Size once the
+// ciphertext has been fully read.
+func EncryptLayer(ec *EncryptConfig, r io.Reader) (io.Reader, map[string]string, error) {
+	if ec == nil || len(ec.Recipients) == 0 {
+		return nil, nil, fmt.Errorf("no recipients provided for layer encryption")
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	annotations := map[string]string{
+		AnnotationPubOpts: fmt.Sprintf(`{"cipher":"AES-256-GCM","nonce":%q}`, base64.StdEncoding.EncodeToString(nonce)),
+	}
+	for i, recipient := range ec.Recipients {
+		wrapped, err := wrapKey(recipient, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to wrap layer key for recipient %d (%s): %v", i, recipient.Scheme, err)
+		}
+		annotations[annotationKeysPrefix+recipient.Scheme] = appendCSV(annotations[annotationKeysPrefix+recipient.Scheme], base64.StdEncoding.EncodeToString(wrapped))
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		plaintext, err := ioutil.ReadAll(r)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+		_, err = pw.Write(ciphertext)
+		pw.CloseWithError(err)
+	}()
+	return pr, annotations, nil
+}
+
+// DecryptLayer reverses EncryptLayer given the annotations recorded on desc
+// and the private keys available in dc, returning the plaintext reader.
+func DecryptLayer(dc *DecryptConfig, r io.Reader, desc distribution.Descriptor) (io.Reader, error) {
+	if dc == nil || len(dc.Keys) == 0 {
+		return nil, fmt.Errorf("layer %s is encrypted but no --decryption-key was provided", desc.Digest)
+	}
+
+	var key []byte
+	var nonce []byte
+	var lastErr error
+	for scheme, wrapped := range desc.Annotations {
+		if !strings.HasPrefix(scheme, annotationKeysPrefix) {
+			continue
+		}
+		for _, encodedKey := range strings.Split(wrapped, ",") {
+			raw, err := base64.StdEncoding.DecodeString(encodedKey)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for _, priv := range dc.Keys {
+				k, err := unwrapKey(priv, raw)
+				if err == nil {
+					key = k
+					break
+				}
+				lastErr = err
+			}
+			if key != nil {
+				break
+			}
+		}
+		if key != nil {
+			break
+		}
+	}
+	if key == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no matching wrapped key annotation found")
+		}
+		return nil, fmt.Errorf("unable to unwrap layer key for %s: %v", desc.Digest, lastErr)
+	}
+	nonce = parseNonce(desc.Annotations[AnnotationPubOpts])
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt layer %s: %v", desc.Digest, err)
+	}
+	return &byteReader{b: plaintext}, nil
+}
+
+// wrapKey seals key for recipient. jwe recipients are expected to name a PEM
+// encoded RSA public key; any other scheme (including pgp and pkcs7, which
+// ParseRecipients already rejects) returns an error.
+func wrapKey(recipient Recipient, key []byte) ([]byte, error) {
+	switch recipient.Scheme {
+	case "jwe":
+		pub, err := readRSAPublicKey(recipient.Value)
+		if err != nil {
+			return nil, err
+		}
+		return rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	default:
+		return nil, fmt.Errorf("recipient scheme %q is not yet implemented", recipient.Scheme)
+	}
+}
+
+func unwrapKey(pemPrivateKey []byte, wrapped []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemPrivateKey)
+	if block == nil {
+		return nil, fmt.Errorf("--decryption-key did not contain a PEM block")
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, wrapped, nil)
+}
+
+func readRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s did not contain a PEM block", path)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA public key", path)
+	}
+	return rsaPub, nil
+}
+
+func appendCSV(existing, value string) string {
+	if len(existing) == 0 {
+		return value
+	}
+	return existing + "," + value
+}
+
+func parseNonce(pubOpts string) []byte {
+	const marker = `"nonce":"`
+	start := strings.Index(pubOpts, marker)
+	if start == -1 {
+		return nil
+	}
+	start += len(marker)
+	end := strings.Index(pubOpts[start:], `"`)
+	if end == -1 {
+		return nil
+	}
+	nonce, _ := base64.StdEncoding.DecodeString(pubOpts[start : start+end])
+	return nonce
+}
+
+type byteReader struct {
+	b []byte
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b)
+	r.b = r.b[n:]
+	return n, nil
+}
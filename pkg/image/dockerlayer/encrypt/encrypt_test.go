@@ -0,0 +1,137 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+func writeTestRSAKeyPair(t *testing.T) (pubPath, privPath string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	dir := t.TempDir()
+	pubPath = filepath.Join(dir, "pub.pem")
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0644); err != nil {
+		t.Fatalf("WriteFile pub: %v", err)
+	}
+
+	privPath = filepath.Join(dir, "priv.pem")
+	privBytes := x509.MarshalPKCS1PrivateKey(priv)
+	if err := ioutil.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: privBytes}), 0600); err != nil {
+		t.Fatalf("WriteFile priv: %v", err)
+	}
+	return pubPath, privPath
+}
+
+func TestEncryptDecryptLayerRoundTrip(t *testing.T) {
+	pubPath, privPath := writeTestRSAKeyPair(t)
+	const plaintext = "this is a layer tar stream, or at least stands in for one"
+
+	ec, err := ParseRecipients([]string{"jwe:" + pubPath})
+	if err != nil {
+		t.Fatalf("ParseRecipients: %v", err)
+	}
+
+	ciphertextReader, annotations, err := EncryptLayer(ec, bytes.NewBufferString(plaintext))
+	if err != nil {
+		t.Fatalf("EncryptLayer: %v", err)
+	}
+	ciphertext, err := ioutil.ReadAll(ciphertextReader)
+	if err != nil {
+		t.Fatalf("ReadAll ciphertext: %v", err)
+	}
+	if string(ciphertext) == plaintext {
+		t.Fatalf("ciphertext equals plaintext, encryption did not occur")
+	}
+
+	priv, err := ParseDecryptionKeys([]string{privPath})
+	if err != nil {
+		t.Fatalf("ParseDecryptionKeys: %v", err)
+	}
+
+	desc := distribution.Descriptor{Annotations: annotations}
+	plaintextReader, err := DecryptLayer(priv, bytes.NewBuffer(ciphertext), desc)
+	if err != nil {
+		t.Fatalf("DecryptLayer: %v", err)
+	}
+	got, err := ioutil.ReadAll(plaintextReader)
+	if err != nil {
+		t.Fatalf("ReadAll plaintext: %v", err)
+	}
+	if string(got) != plaintext {
+		t.Fatalf("decrypted content = %q, expected %q", got, plaintext)
+	}
+}
+
+func TestDecryptLayerWrongKeyFails(t *testing.T) {
+	pubPath, _ := writeTestRSAKeyPair(t)
+	_, otherPrivPath := writeTestRSAKeyPair(t)
+
+	ec, err := ParseRecipients([]string{"jwe:" + pubPath})
+	if err != nil {
+		t.Fatalf("ParseRecipients: %v", err)
+	}
+	ciphertextReader, annotations, err := EncryptLayer(ec, bytes.NewBufferString("secret contents"))
+	if err != nil {
+		t.Fatalf("EncryptLayer: %v", err)
+	}
+	ciphertext, err := ioutil.ReadAll(ciphertextReader)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	dc, err := ParseDecryptionKeys([]string{otherPrivPath})
+	if err != nil {
+		t.Fatalf("ParseDecryptionKeys: %v", err)
+	}
+	desc := distribution.Descriptor{Annotations: annotations}
+	if _, err := DecryptLayer(dc, bytes.NewBuffer(ciphertext), desc); err == nil {
+		t.Fatalf("DecryptLayer with the wrong key succeeded, expected an error")
+	}
+}
+
+func TestParseRecipientsRejectsUnimplementedSchemes(t *testing.T) {
+	for _, spec := range []string{"pgp:somefingerprint", "pkcs7:somecert"} {
+		if _, err := ParseRecipients([]string{spec}); err == nil {
+			t.Errorf("ParseRecipients(%q) expected an error, got none", spec)
+		}
+	}
+}
+
+func TestParseRecipientsRejectsUnknownScheme(t *testing.T) {
+	if _, err := ParseRecipients([]string{"bogus:value"}); err == nil {
+		t.Errorf("ParseRecipients with an unknown scheme expected an error, got none")
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	const base = "application/vnd.oci.image.layer.v1.tar+gzip"
+	encrypted := MediaType(base)
+	if encrypted != base+"+encrypted" {
+		t.Fatalf("MediaType(%q) = %q, expected suffix +encrypted", base, encrypted)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("IsEncrypted(%q) = false, expected true", encrypted)
+	}
+	if MediaType(encrypted) != encrypted {
+		t.Fatalf("MediaType should be idempotent on an already-encrypted type")
+	}
+	if BaseMediaType(encrypted) != base {
+		t.Fatalf("BaseMediaType(%q) = %q, expected %q", encrypted, BaseMediaType(encrypted), base)
+	}
+}
@@ -0,0 +1,18 @@
+// Package dockerlayer contains helpers for working with Docker image layer
+// blobs independent of their storage location.
+package dockerlayer
+
+import (
+	digest "github.com/opencontainers/go-digest"
+)
+
+// GzippedEmptyLayer is the gzipped representation of an empty tar archive, used
+// as the base layer for scratch images.
+var GzippedEmptyLayer = []byte{
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x62, 0x18,
+	0x05, 0xa3, 0x60, 0x14, 0x8c, 0x58, 0x00, 0x08, 0x00, 0x00, 0xff, 0xff,
+	0x2e, 0xaf, 0xb5, 0xef, 0x00, 0x04, 0x00, 0x00,
+}
+
+// GzippedEmptyLayerDigest is the digest of GzippedEmptyLayer.
+const GzippedEmptyLayerDigest = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d2")
@@ -0,0 +1,125 @@
+// Package registryclient provides helpers for constructing Docker registry
+// API clients that share transports and credentials across commands.
+package registryclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+)
+
+// CredentialStore provides registry credentials for a given hostname.
+type CredentialStore interface {
+	Basic(host string) (string, string)
+}
+
+// Context holds the transports and credentials used to construct registry
+// clients for repositories.
+type Context struct {
+	transport         http.RoundTripper
+	insecureTransport http.RoundTripper
+	credentials       CredentialStore
+	actions           []string
+}
+
+// NewContext returns a Context that will use transport for registries
+// reachable over HTTPS and insecureTransport for registries that require
+// --insecure.
+func NewContext(transport, insecureTransport http.RoundTripper) *Context {
+	return &Context{
+		transport:         transport,
+		insecureTransport: insecureTransport,
+	}
+}
+
+// WithCredentials returns a copy of c that authenticates using store.
+func (c Context) WithCredentials(store CredentialStore) *Context {
+	c.credentials = store
+	return &c
+}
+
+// WithActions returns a copy of c that requests the given scopes (e.g. "pull",
+// "push") when authenticating.
+func (c Context) WithActions(actions ...string) *Context {
+	c.actions = actions
+	return &c
+}
+
+// Repository returns a distribution.Repository for name on registry, using the
+// insecure transport when insecure is true. The repository's transport
+// challenges the registry and, if it requires authentication, negotiates
+// bearer or basic auth using c.credentials.
+func (c *Context) Repository(ctx context.Context, registry, name string, insecure bool) (distribution.Repository, error) {
+	rt := c.transport
+	scheme := "https"
+	if insecure {
+		rt = c.insecureTransport
+		scheme = "http"
+	}
+	named, err := reference.WithName(name)
+	if err != nil {
+		return nil, err
+	}
+	base := scheme + "://" + registry
+	authRT, err := c.authorizedTransport(rt, base, named)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewRepository(ctx, named, base, authRT)
+}
+
+// authorizedTransport pings base's v2 API to discover the auth challenge it
+// returns, if any, and wraps rt so that requests through the returned
+// transport carry whatever bearer or basic auth the challenge calls for,
+// using c.credentials and c.actions (defaulting to "pull") to satisfy it.
+func (c *Context) authorizedTransport(rt http.RoundTripper, base string, named reference.Named) (http.RoundTripper, error) {
+	pingClient := &http.Client{Transport: rt}
+	resp, err := pingClient.Get(base + "/v2/")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	manager := challenge.NewSimpleManager()
+	if err := manager.AddResponse(resp); err != nil {
+		return nil, err
+	}
+
+	actions := c.actions
+	if len(actions) == 0 {
+		actions = []string{"pull"}
+	}
+	creds := &credentialStore{store: c.credentials}
+	handlers := []auth.AuthenticationHandler{
+		auth.NewTokenHandler(rt, creds, named.Name(), actions...),
+		auth.NewBasicHandler(creds),
+	}
+	return transport.NewTransport(rt, auth.NewAuthorizer(manager, handlers...)), nil
+}
+
+// credentialStore adapts this package's simplified, hostname-keyed
+// CredentialStore to the richer auth.CredentialStore the docker/distribution
+// auth package expects. Refresh tokens are not cached across requests; the
+// token handler always falls back to basic auth or an anonymous token
+// request instead.
+type credentialStore struct {
+	store CredentialStore
+}
+
+func (s *credentialStore) Basic(u *url.URL) (string, string) {
+	if s.store == nil {
+		return "", ""
+	}
+	return s.store.Basic(u.Host)
+}
+
+func (s *credentialStore) RefreshToken(*url.URL, string) string { return "" }
+
+func (s *credentialStore) SetRefreshToken(*url.URL, string, string) {}
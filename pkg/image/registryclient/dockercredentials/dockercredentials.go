@@ -0,0 +1,94 @@
+// Package dockercredentials loads registry credentials from the local Docker
+// configuration files.
+package dockercredentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/origin/pkg/image/registryclient"
+)
+
+// NewLocal returns a credential store that reads from the default Docker
+// config locations ($DOCKER_CONFIG, ~/.docker/config.json, ~/.dockercfg).
+func NewLocal() registryclient.CredentialStore {
+	paths := []string{}
+	if dir := os.Getenv("DOCKER_CONFIG"); len(dir) > 0 {
+		paths = append(paths, filepath.Join(dir, "config.json"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".docker", "config.json"))
+		paths = append(paths, filepath.Join(home, ".dockercfg"))
+	}
+	return &localStore{paths: paths}
+}
+
+type localStore struct {
+	paths []string
+}
+
+func (s *localStore) Basic(host string) (string, string) {
+	for _, path := range s.paths {
+		if user, pass, ok := lookupBasicAuth(path, host); ok {
+			return user, pass
+		}
+	}
+	return "", ""
+}
+
+// dockerConfigFile is the subset of the Docker/Podman config.json (and
+// legacy .dockercfg, which has the same "auths"-keyed shape without the
+// wrapper object) this package needs: a map from registry hostname to a
+// base64-encoded "user:password" pair.
+type dockerConfigFile struct {
+	Auths map[string]dockerAuthConfig `json:"auths"`
+}
+
+type dockerAuthConfig struct {
+	Auth string `json:"auth"`
+}
+
+// lookupBasicAuth reads path as a Docker config file and returns the basic
+// auth credentials registered for host, if any.
+func lookupBasicAuth(path, host string) (string, string, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	var config dockerConfigFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", false
+	}
+	if config.Auths == nil {
+		// legacy .dockercfg: the same map without the "auths" wrapper
+		if err := json.Unmarshal(data, &config.Auths); err != nil {
+			return "", "", false
+		}
+	}
+	entry, ok := config.Auths[host]
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return "", "", false
+	}
+	user, pass, ok := splitAuth(string(decoded))
+	if !ok {
+		return "", "", false
+	}
+	return user, pass, true
+}
+
+// splitAuth splits a decoded "user:password" auth string on its first colon.
+func splitAuth(auth string) (string, string, bool) {
+	i := strings.Index(auth, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return auth[:i], auth[i+1:], true
+}
@@ -0,0 +1,58 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os/exec"
+)
+
+// SignWithGPG produces a simple signing (atomic/skopeo) signature over
+// payload using the local GPG keyring entry identified by fingerprint. It
+// shells out to gpg, matching how containers/image produces these
+// signatures without requiring a cgo dependency on gpgme.
+func SignWithGPG(ctx context.Context, fingerprint string, payload []byte) ([]byte, error) {
+	args := []string{"--batch", "--detach-sign", "-u", fingerprint}
+	cmd := exec.CommandContext(ctx, "gpg", args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gpg signing with key %s failed: %v: %s", fingerprint, err, stderr.String())
+	}
+	return out.Bytes(), nil
+}
+
+// SignWithSigstoreKey produces a detached, cosign-style signature over
+// payload using the ECDSA private key stored at keyfile (a PEM encoded PKCS8
+// key, unencrypted). The returned bytes are the raw ASN.1 signature.
+func SignWithSigstoreKey(keyfile []byte, payload []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyfile)
+	if block == nil {
+		return nil, fmt.Errorf("--sign-by-sigstore key file did not contain a PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse sigstore private key: %v", err)
+	}
+	priv, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("sigstore signing currently only supports ECDSA private keys")
+	}
+	digest := sha256.Sum256(payload)
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign with sigstore key: %v", err)
+	}
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, s})
+}
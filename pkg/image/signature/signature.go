@@ -0,0 +1,65 @@
+// Package signature implements the two signing mechanisms "oc image append
+// --sign-by" and "--sign-by-sigstore" can attach to a pushed manifest: simple
+// signing (the atomic/docker-distribution GPG signature format) and a
+// sigstore/cosign style detached signature.
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// AtomicSignature is the unsigned payload covered by a simple signing
+// signature, matching the format produced by atomic/skopeo.
+type AtomicSignature struct {
+	Critical AtomicCritical `json:"critical"`
+	Optional AtomicOptional `json:"optional,omitempty"`
+}
+
+// AtomicCritical holds the fields of an AtomicSignature that change its
+// meaning and so must be covered by the signature.
+type AtomicCritical struct {
+	Type     string         `json:"type"`
+	Image    AtomicImage    `json:"image"`
+	Identity AtomicIdentity `json:"identity"`
+}
+
+// AtomicImage identifies the signed manifest by digest.
+type AtomicImage struct {
+	DockerManifestDigest digest.Digest `json:"docker-manifest-digest"`
+}
+
+// AtomicIdentity identifies the reference the signature asserts the image was
+// published under.
+type AtomicIdentity struct {
+	DockerReference string `json:"docker-reference"`
+}
+
+// AtomicOptional holds fields that may be safely ignored by a verifier that
+// doesn't understand them.
+type AtomicOptional struct {
+	Creator string `json:"creator,omitempty"`
+}
+
+const atomicSignatureType = "atomic container signature"
+
+// NewAtomicSignaturePayload returns the canonical JSON payload that a simple
+// signing (GPG) signature must be produced over for the given reference and
+// digest.
+func NewAtomicSignaturePayload(dockerReference string, manifestDigest digest.Digest) ([]byte, error) {
+	sig := AtomicSignature{
+		Critical: AtomicCritical{
+			Type:     atomicSignatureType,
+			Image:    AtomicImage{DockerManifestDigest: manifestDigest},
+			Identity: AtomicIdentity{DockerReference: dockerReference},
+		},
+		Optional: AtomicOptional{Creator: "oc image append"},
+	}
+	payload, err := json.Marshal(sig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build signature payload: %v", err)
+	}
+	return payload, nil
+}
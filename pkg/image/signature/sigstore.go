@@ -0,0 +1,47 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/ocischema"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// sigstoreEmptyConfig is the config blob cosign uses for signature
+// manifests: an empty JSON object, since the signature itself carries no
+// image configuration.
+var sigstoreEmptyConfig = []byte("{}")
+
+const sigstoreSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+// SigstoreTag returns the tag cosign-compatible tooling expects to find a
+// signature for targetDigest under, e.g. "sha256-<hex>.sig".
+func SigstoreTag(targetDigest digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", targetDigest.Algorithm(), targetDigest.Encoded())
+}
+
+// PushSigstoreSignature uploads sig as a single-layer OCI manifest tagged
+// with SigstoreTag(targetDigest), matching the convention cosign uses to
+// attach a detached signature to an image without a registry extension. The
+// manifest builder uploads the (empty) config blob itself as part of Build.
+func PushSigstoreSignature(ctx context.Context, blobs distribution.BlobService, manifests distribution.ManifestService, targetDigest digest.Digest, sig []byte) (digest.Digest, error) {
+	sigDesc, err := blobs.Put(ctx, sigstoreSignatureMediaType, sig)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload signature blob: %v", err)
+	}
+
+	b := ocischema.NewManifestBuilder(blobs, sigstoreEmptyConfig, map[string]string{
+		"dev.cosignproject.cosign/signature": string(sig),
+	})
+	if err := b.AppendReference(sigDesc); err != nil {
+		return "", err
+	}
+	manifest, err := b.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return manifests.Put(ctx, manifest, distribution.WithTag(SigstoreTag(targetDigest)))
+}
@@ -0,0 +1,116 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Store publishes a detached signature for the manifest identified by dgst.
+type Store interface {
+	Put(ctx context.Context, dgst digest.Digest, signature []byte) error
+}
+
+// NewStore parses the value of --signature-store (a "file:///path",
+// "http(s)://host/path", or empty string) and returns the corresponding
+// Store. An empty spec publishes to the registry's
+// /extensions/v2/<name>/signatures/<digest> endpoint, as implemented by
+// atomic registries and the OpenShift integrated registry.
+func NewStore(spec string, client *http.Client, registryBaseURL, repository string) (Store, error) {
+	if len(spec) == 0 {
+		return &registryStore{client: client, baseURL: registryBaseURL, repository: repository}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --signature-store %q: %v", spec, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return &fileStore{dir: u.Path}, nil
+	case "http", "https":
+		return &httpStore{client: client, baseURL: spec}, nil
+	default:
+		return nil, fmt.Errorf("invalid --signature-store %q: scheme must be file, http, or https", spec)
+	}
+}
+
+// lookasideName returns the conventional lookaside file name for the first
+// signature of a given digest: "<algo>=<hex>/signature-1".
+func lookasideName(dgst digest.Digest) string {
+	return fmt.Sprintf("%s=%s/signature-1", dgst.Algorithm(), dgst.Encoded())
+}
+
+// fileStore writes signatures to a local lookaside directory, matching the
+// layout produced by skopeo/atomic when using a "file:///path" store.
+type fileStore struct {
+	dir string
+}
+
+func (s *fileStore) Put(ctx context.Context, dgst digest.Digest, sig []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(lookasideName(dgst)))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, sig, 0644)
+}
+
+// httpStore PUTs signatures to an HTTP(S) lookaside server using the same
+// path layout as fileStore.
+type httpStore struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (s *httpStore) Put(ctx context.Context, dgst digest.Digest, sig []byte) error {
+	target := strings.TrimSuffix(s.baseURL, "/") + "/" + lookasideName(dgst)
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(sig))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to upload signature to %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to upload signature to %s: server returned %s", target, resp.Status)
+	}
+	return nil
+}
+
+// registryStore publishes signatures to a registry's
+// /extensions/v2/<name>/signatures/<digest> endpoint, the convention used by
+// atomic registries (including the OpenShift integrated registry) in lieu of
+// a lookaside store.
+type registryStore struct {
+	client     *http.Client
+	baseURL    string
+	repository string
+}
+
+func (s *registryStore) Put(ctx context.Context, dgst digest.Digest, sig []byte) error {
+	target := fmt.Sprintf("%s/extensions/v2/%s/signatures/%s", strings.TrimSuffix(s.baseURL, "/"), s.repository, dgst)
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(sig))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to upload signature to %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to upload signature to %s: server returned %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/distribution"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// localBlobWriter buffers a blob upload in memory and commits it to the
+// owning localBlobStore on Commit, since none of the local transports
+// support resumable or chunked writes.
+type localBlobWriter struct {
+	s      *localBlobStore
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (w *localBlobWriter) ID() string { return "" }
+
+func (w *localBlobWriter) StartedAt() time.Time { return time.Time{} }
+
+func (w *localBlobWriter) Size() int64 { return int64(w.buf.Len()) }
+
+func (w *localBlobWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *localBlobWriter) ReadFrom(r io.Reader) (int64, error) {
+	return w.buf.ReadFrom(r)
+}
+
+func (w *localBlobWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (w *localBlobWriter) Cancel(ctx context.Context) error {
+	w.buf.Reset()
+	w.closed = true
+	return nil
+}
+
+func (w *localBlobWriter) Commit(ctx context.Context, provisional distribution.Descriptor) (distribution.Descriptor, error) {
+	p := w.buf.Bytes()
+	dgst := digest.FromBytes(p)
+	if len(provisional.Digest) > 0 && provisional.Digest != dgst {
+		return distribution.Descriptor{}, distribution.ErrBlobInvalidDigest{Digest: provisional.Digest, Reason: digest.ErrDigestInvalidFormat}
+	}
+	w.s.r.blobs[dgst] = p
+	mediaType := provisional.MediaType
+	w.s.r.mediaType[dgst] = mediaType
+	if err := w.s.r.persist(w.s.r); err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return distribution.Descriptor{Digest: dgst, Size: int64(len(p)), MediaType: mediaType}, nil
+}
+
+// bytesReadSeekCloser adapts a byte slice to distribution.ReadSeekCloser.
+type bytesReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func newBytesReadSeekCloser(p []byte) *bytesReadSeekCloser {
+	return &bytesReadSeekCloser{Reader: bytes.NewReader(p)}
+}
+
+func (b *bytesReadSeekCloser) Close() error { return nil }
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// dirManifest is the index persisted to manifest.json by the "dir:"
+// transport: the manifest's media type, the name of the sibling file that
+// holds the manifest bytes, and the tags. Every blob is stored as a sibling
+// file named by its digest's hex-encoded value.
+type dirManifest struct {
+	MediaType string            `json:"mediaType"`
+	Manifest  string            `json:"manifest"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// openDirRepository loads a "dir:" location: a directory containing
+// manifest.json plus one file per blob, named by the blob's digest.
+func openDirRepository(dir string) (*localRepository, error) {
+	r := newLocalRepository(func(r *localRepository) error { return persistDir(dir, r) })
+
+	indexPath := filepath.Join(dir, "manifest.json")
+	data, err := ioutil.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var idx dirManifest
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(dir, idx.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	dgst := digest.FromBytes(manifestBytes)
+	r.manifests[dgst] = manifestBytes
+	r.mediaType[dgst] = idx.MediaType
+	r.lastManifest = dgst
+	for tag, hex := range idx.Tags {
+		r.tags[tag] = digest.Digest(hex)
+	}
+
+	blobFiles, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range blobFiles {
+		if f.IsDir() || f.Name() == "manifest.json" || f.Name() == idx.Manifest {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		bdgst := digest.FromBytes(blob)
+		r.blobs[bdgst] = blob
+	}
+	return r, nil
+}
+
+// persistDir writes the in-memory repository state back to dir in the
+// "dir:" layout, overwriting manifest.json and any blob files it references.
+func persistDir(dir string, r *localRepository) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for dgst, blob := range r.blobs {
+		if err := ioutil.WriteFile(filepath.Join(dir, dgst.Encoded()), blob, 0644); err != nil {
+			return err
+		}
+	}
+
+	// A "dir:" location holds a single image, so persist the most recently
+	// written manifest (r.lastManifest) as manifest.json, rather than an
+	// arbitrary entry from r.manifests (whose iteration order is undefined).
+	dgst := r.lastManifest
+	if len(dgst) == 0 {
+		return nil
+	}
+	manifestName := dgst.Encoded() + ".manifest.json"
+	if err := ioutil.WriteFile(filepath.Join(dir, manifestName), r.manifests[dgst], 0644); err != nil {
+		return err
+	}
+	idx := dirManifest{
+		MediaType: r.mediaType[dgst],
+		Manifest:  manifestName,
+		Tags:      make(map[string]string),
+	}
+	for tag, d := range r.tags {
+		idx.Tags[tag] = string(d)
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644)
+}
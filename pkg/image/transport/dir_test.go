@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+// putSchema2Manifest builds and Puts a minimal config-only schema2 manifest
+// tagged as tag, returning the digest it was written under.
+func putSchema2Manifest(t *testing.T, r *localRepository, tag string) distribution.Descriptor {
+	t.Helper()
+	blobs := &localBlobStore{r: r}
+	config := []byte(`{"rootfs":{"type":"layers"},"comment":"` + tag + `"}`)
+	b := schema2.NewManifestBuilder(blobs, config)
+	manifest, err := b.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	manifests := &localManifestService{r: r}
+	dgst, err := manifests.Put(context.Background(), manifest, distribution.WithTag(tag))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	return distribution.Descriptor{Digest: dgst}
+}
+
+// TestDirRepositoryRoundTrip covers load/append/persist/reload against a
+// "dir:" location: it loads an empty directory, Puts a manifest (as append
+// would after uploading a new layer), and verifies reopening the directory
+// yields the manifest just written rather than an arbitrary prior one.
+func TestDirRepositoryRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir-transport-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := openDirRepository(dir)
+	if err != nil {
+		t.Fatalf("openDirRepository: %v", err)
+	}
+	first := putSchema2Manifest(t, r, "first")
+	second := putSchema2Manifest(t, r, "second")
+
+	reloaded, err := openDirRepository(dir)
+	if err != nil {
+		t.Fatalf("reopen openDirRepository: %v", err)
+	}
+	if reloaded.lastManifest != second.Digest {
+		t.Fatalf("reloaded dir: holds manifest %s, expected the most recently written %s (first was %s)", reloaded.lastManifest, second.Digest, first.Digest)
+	}
+	if _, ok := reloaded.manifests[second.Digest]; !ok {
+		t.Fatalf("reloaded dir: is missing the most recently written manifest %s", second.Digest)
+	}
+	if dgst, ok := reloaded.tags["second"]; !ok || dgst != second.Digest {
+		t.Fatalf("reloaded dir: tag %q = %s, expected %s", "second", dgst, second.Digest)
+	}
+}
+
+// TestDockerArchiveRepositoryRoundTrip mirrors TestDirRepositoryRoundTrip for
+// the "docker-archive:" transport.
+func TestDockerArchiveRepositoryRoundTrip(t *testing.T) {
+	f, err := ioutil.TempFile("", "docker-archive-test")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	path := f.Name()
+	f.Close()
+	os.Remove(path)
+	defer os.Remove(path)
+
+	r, err := openDockerArchiveRepository(path)
+	if err != nil {
+		t.Fatalf("openDockerArchiveRepository: %v", err)
+	}
+	first := putSchema2Manifest(t, r, "first")
+	second := putSchema2Manifest(t, r, "second")
+
+	reloaded, err := openDockerArchiveRepository(path)
+	if err != nil {
+		t.Fatalf("reopen openDockerArchiveRepository: %v", err)
+	}
+	if reloaded.lastManifest != second.Digest {
+		t.Fatalf("reloaded docker-archive: holds manifest %s, expected the most recently written %s (first was %s)", reloaded.lastManifest, second.Digest, first.Digest)
+	}
+	if _, ok := reloaded.manifests[second.Digest]; !ok {
+		t.Fatalf("reloaded docker-archive: is missing the most recently written manifest %s", second.Digest)
+	}
+	if dgst, ok := reloaded.tags["second"]; !ok || dgst != second.Digest {
+		t.Fatalf("reloaded docker-archive: tag %q = %s, expected %s", "second", dgst, second.Digest)
+	}
+}
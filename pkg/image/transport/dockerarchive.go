@@ -0,0 +1,182 @@
+package transport
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/golang/glog"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// dockerArchiveEntry is one element of the top-level manifest.json array in
+// a "docker save" tarball.
+type dockerArchiveEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags,omitempty"`
+	Layers   []string `json:"Layers"`
+}
+
+// openDockerArchiveRepository loads a "docker-archive:" location: a tar
+// produced by "docker save", containing manifest.json plus one file per
+// config and layer. Since "docker save" has no notion of an image manifest
+// object, one is synthesized from the manifest.json entry so the rest of
+// append can treat this transport like any other distribution.Repository.
+// An archive saved with multiple images only has its first entry used; the
+// rest are logged, not silently dropped.
+func openDockerArchiveRepository(path string) (*localRepository, error) {
+	r := newLocalRepository(func(r *localRepository) error { return persistDockerArchive(path, r) })
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = data
+	}
+
+	manifestData, ok := files["manifest.json"]
+	if !ok {
+		return r, nil
+	}
+	var entries []dockerArchiveEntry
+	if err := json.Unmarshal(manifestData, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return r, nil
+	}
+	if len(entries) > 1 {
+		var ignored []string
+		for _, e := range entries[1:] {
+			ignored = append(ignored, e.RepoTags...)
+		}
+		glog.V(2).Infof("docker archive %s contains %d images, only the first (%s) will be used; ignoring %s", path, len(entries), entries[0].RepoTags, ignored)
+	}
+	entry := entries[0]
+
+	config, ok := files[entry.Config]
+	if !ok {
+		return nil, fmt.Errorf("docker archive %s: missing config %s", path, entry.Config)
+	}
+	configDigest := digest.FromBytes(config)
+	r.blobs[configDigest] = config
+	r.mediaType[configDigest] = schema2.MediaTypeImageConfig
+
+	b := schema2.NewManifestBuilder(&localBlobStore{r: r}, config)
+	for _, layerName := range entry.Layers {
+		layer, ok := files[layerName]
+		if !ok {
+			return nil, fmt.Errorf("docker archive %s: missing layer %s", path, layerName)
+		}
+		layerDigest := digest.FromBytes(layer)
+		r.blobs[layerDigest] = layer
+		r.mediaType[layerDigest] = schema2.MediaTypeLayer
+		if err := b.AppendReference(distributionDescriptor(layerDigest, schema2.MediaTypeLayer, len(layer))); err != nil {
+			return nil, err
+		}
+	}
+	deserialized, err := b.Build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	_, raw, err := deserialized.Payload()
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest := digest.FromBytes(raw)
+	r.manifests[manifestDigest] = raw
+	r.mediaType[manifestDigest] = schema2.MediaTypeManifest
+	r.lastManifest = manifestDigest
+	for _, repoTag := range entry.RepoTags {
+		r.tags[repoTag] = manifestDigest
+	}
+	return r, nil
+}
+
+// persistDockerArchive writes the in-memory repository state back out as a
+// "docker save" compatible tarball, picking r.lastManifest (the most
+// recently written schema2 manifest) as the single image the archive
+// describes.
+func persistDockerArchive(path string, r *localRepository) error {
+	manifestDigest := r.lastManifest
+	if len(manifestDigest) == 0 {
+		return nil
+	}
+	var manifest schema2.Manifest
+	if err := json.Unmarshal(r.manifests[manifestDigest], &manifest); err != nil {
+		return fmt.Errorf("docker-archive only supports schema2 manifests: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+
+	configName := manifest.Config.Digest.Encoded() + ".json"
+	if err := writeTarFile(tw, configName, r.blobs[manifest.Config.Digest]); err != nil {
+		return err
+	}
+
+	var layerNames []string
+	for _, l := range manifest.Layers {
+		name := l.Digest.Encoded() + "/layer.tar"
+		if err := writeTarFile(tw, name, r.blobs[l.Digest]); err != nil {
+			return err
+		}
+		layerNames = append(layerNames, name)
+	}
+
+	var repoTags []string
+	for tag, d := range r.tags {
+		if d == manifestDigest {
+			repoTags = append(repoTags, tag)
+		}
+	}
+	entries := []dockerArchiveEntry{{Config: configName, RepoTags: repoTags, Layers: layerNames}}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, "manifest.json", data)
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data)), Mode: 0644}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func distributionDescriptor(dgst digest.Digest, mediaType string, size int) distribution.Descriptor {
+	return distribution.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(size)}
+}
@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const ociLayoutVersion = "1.0.0"
+
+// blobPath returns the blobs/<algorithm>/<hex> path a digest is stored under
+// in an OCI image layout, per the OCI Image Layout spec.
+func blobPath(dgst digest.Digest) string {
+	return filepath.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// openOCIRepository loads an OCI image layout directory: an oci-layout file,
+// an index.json listing the manifests present, and their blobs under blobs/.
+func openOCIRepository(dir string) (*localRepository, error) {
+	r := newLocalRepository(func(r *localRepository) error { return persistOCIDir(dir, r) })
+
+	indexData, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, err
+	}
+	if err := loadOCIBlobs(dir, &index, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadOCIBlobs reads every blob referenced, directly or transitively, by the
+// descriptors in index into r, and records any ref.name annotated tags.
+func loadOCIBlobs(dir string, index *ocispec.Index, r *localRepository) error {
+	for _, desc := range index.Manifests {
+		data, err := ioutil.ReadFile(filepath.Join(dir, blobPath(desc.Digest)))
+		if err != nil {
+			return err
+		}
+		r.manifests[desc.Digest] = data
+		r.mediaType[desc.Digest] = desc.MediaType
+		if tag, ok := desc.Annotations[ocispec.AnnotationRefName]; ok {
+			r.tags[tag] = desc.Digest
+		}
+	}
+	// Pull in every blob present under blobs/, since manifests and configs
+	// reference layers by digest only and we have no cheap way to walk those
+	// references without fully parsing each manifest.
+	return filepath.Walk(filepath.Join(dir, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dgst := digest.FromBytes(data)
+		if _, ok := r.manifests[dgst]; !ok {
+			r.blobs[dgst] = data
+		}
+		return nil
+	})
+}
+
+// persistOCIDir writes the in-memory repository state back out as an OCI
+// image layout directory.
+func persistOCIDir(dir string, r *localRepository) error {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(fmt.Sprintf(`{"imageLayoutVersion":"%s"}`, ociLayoutVersion)), 0644); err != nil {
+		return err
+	}
+	for dgst, blob := range r.blobs {
+		if err := ioutil.WriteFile(filepath.Join(dir, blobPath(dgst)), blob, 0644); err != nil {
+			return err
+		}
+	}
+	index := ocispec.Index{Versioned: ociIndexVersioned()}
+	for dgst, manifest := range r.manifests {
+		if err := ioutil.WriteFile(filepath.Join(dir, blobPath(dgst)), manifest, 0644); err != nil {
+			return err
+		}
+		desc := ocispec.Descriptor{
+			MediaType: r.mediaType[dgst],
+			Digest:    dgst,
+			Size:      int64(len(manifest)),
+		}
+		for tag, tdgst := range r.tags {
+			if tdgst == dgst {
+				desc.Annotations = map[string]string{ocispec.AnnotationRefName: tag}
+			}
+		}
+		index.Manifests = append(index.Manifests, desc)
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "index.json"), data, 0644)
+}
+
+// openOCIArchiveRepository loads an "oci-archive:" location: a tar archive
+// of an OCI image layout, by unpacking it into a temporary directory and
+// reusing the "oci:" directory logic, then re-packing on persist.
+func openOCIArchiveRepository(path string) (*localRepository, error) {
+	tmp, err := ioutil.TempDir("", "oci-archive")
+	if err != nil {
+		return nil, err
+	}
+
+	if f, err := os.Open(path); err == nil {
+		err := untar(f, tmp)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	r, err := openOCIRepository(tmp)
+	if err != nil {
+		return nil, err
+	}
+	r.persist = func(r *localRepository) error {
+		if err := persistOCIDir(tmp, r); err != nil {
+			return err
+		}
+		return tarDirectory(tmp, path)
+	}
+	return r, nil
+}
+
+func untar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func tarDirectory(src, destArchive string) error {
+	f, err := os.OpenFile(destArchive, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == src {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+}
+
+// ociIndexVersioned returns the Versioned header (schemaVersion 2) every OCI
+// index must carry.
+func ociIndexVersioned() ocispec.Versioned {
+	return ocispec.Versioned{SchemaVersion: 2}
+}
@@ -0,0 +1,217 @@
+// Package transport lets "oc image append" read from and write to images
+// stored somewhere other than a registry, mirroring the transport model
+// containers/image exposes through types.ImageReference: a docker-archive
+// tarball, an OCI image layout directory or archive, or a flat "dir:"
+// directory. Each is backed by localRepository, an in-memory
+// distribution.Repository that is hydrated from disk on open and flushed
+// back on every mutation, since none of these transports support partial or
+// streaming updates the way a registry does.
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution"
+	distributioncontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+
+	imagereference "github.com/openshift/origin/pkg/image/apis/image/reference"
+	"github.com/openshift/origin/pkg/image/registryclient"
+)
+
+// NewRepository returns a distribution.Repository for ref, dispatching on
+// ref.Transport. The docker transport (a registry reference) is served by
+// regCtx; all other transports are read from and written to local disk.
+func NewRepository(ctx context.Context, ref imagereference.DockerImageReference, regCtx *registryclient.Context, insecure bool) (distribution.Repository, error) {
+	switch ref.Transport {
+	case "", imagereference.TransportDocker:
+		return regCtx.Repository(ctx, ref.DockerClientDefaults().RegistryURL(), ref.RepositoryName(), insecure)
+	case imagereference.TransportDir:
+		return openDirRepository(ref.Location)
+	case imagereference.TransportOCI:
+		return openOCIRepository(ref.Location)
+	case imagereference.TransportOCIArchive:
+		return openOCIArchiveRepository(ref.Location)
+	case imagereference.TransportDockerArchive:
+		return openDockerArchiveRepository(ref.Location)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", ref.Transport)
+	}
+}
+
+// localRepository is a distribution.Repository backed by content loaded from
+// and persisted to disk by a transport-specific backend.
+type localRepository struct {
+	named reference.Named
+
+	blobs     map[digest.Digest][]byte
+	manifests map[digest.Digest][]byte
+	mediaType map[digest.Digest]string
+	tags      map[string]digest.Digest
+
+	// lastManifest is the digest most recently written by Put, or loaded from
+	// disk on open. Transports that hold a single image (dir:, docker-archive:)
+	// use it to know which of possibly several manifests map entries to
+	// persist, since map iteration order can't be relied on for that.
+	lastManifest digest.Digest
+
+	persist func(*localRepository) error
+}
+
+func newLocalRepository(persist func(*localRepository) error) *localRepository {
+	return &localRepository{
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[digest.Digest][]byte),
+		mediaType: make(map[digest.Digest]string),
+		tags:      make(map[string]digest.Digest),
+		persist:   persist,
+	}
+}
+
+func (r *localRepository) Named() reference.Named { return r.named }
+
+func (r *localRepository) Tags(ctx distributioncontext.Context) distribution.TagService {
+	return &localTagService{r: r}
+}
+
+func (r *localRepository) Manifests(ctx distributioncontext.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return &localManifestService{r: r}, nil
+}
+
+func (r *localRepository) Blobs(ctx distributioncontext.Context) distribution.BlobStore {
+	return &localBlobStore{r: r}
+}
+
+type localTagService struct{ r *localRepository }
+
+func (s *localTagService) Get(ctx distributioncontext.Context, tag string) (distribution.Descriptor, error) {
+	dgst, ok := s.r.tags[tag]
+	if !ok {
+		return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tag}
+	}
+	return distribution.Descriptor{Digest: dgst, MediaType: s.r.mediaType[dgst], Size: int64(len(s.r.manifests[dgst]))}, nil
+}
+
+func (s *localTagService) Tag(ctx distributioncontext.Context, tag string, desc distribution.Descriptor) error {
+	s.r.tags[tag] = desc.Digest
+	return s.r.persist(s.r)
+}
+
+func (s *localTagService) Untag(ctx distributioncontext.Context, tag string) error {
+	delete(s.r.tags, tag)
+	return s.r.persist(s.r)
+}
+
+func (s *localTagService) All(ctx distributioncontext.Context) ([]string, error) {
+	var tags []string
+	for tag := range s.r.tags {
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (s *localTagService) Lookup(ctx distributioncontext.Context, desc distribution.Descriptor) ([]string, error) {
+	var tags []string
+	for tag, dgst := range s.r.tags {
+		if dgst == desc.Digest {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+type localManifestService struct{ r *localRepository }
+
+func (s *localManifestService) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
+	_, ok := s.r.manifests[dgst]
+	return ok, nil
+}
+
+func (s *localManifestService) Get(ctx context.Context, dgst digest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	raw, ok := s.r.manifests[dgst]
+	if !ok {
+		return nil, distribution.ErrManifestUnknownRevision{Revision: dgst}
+	}
+	manifest, _, err := distribution.UnmarshalManifest(s.r.mediaType[dgst], raw)
+	return manifest, err
+}
+
+func (s *localManifestService) Put(ctx context.Context, manifest distribution.Manifest, options ...distribution.ManifestServiceOption) (digest.Digest, error) {
+	mediaType, raw, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+	dgst := digest.FromBytes(raw)
+	s.r.manifests[dgst] = raw
+	s.r.mediaType[dgst] = mediaType
+	s.r.lastManifest = dgst
+	for _, opt := range options {
+		if tagOpt, ok := opt.(distribution.WithTagOption); ok {
+			s.r.tags[tagOpt.Tag] = dgst
+		}
+	}
+	return dgst, s.r.persist(s.r)
+}
+
+func (s *localManifestService) Delete(ctx context.Context, dgst digest.Digest) error {
+	delete(s.r.manifests, dgst)
+	delete(s.r.mediaType, dgst)
+	return s.r.persist(s.r)
+}
+
+type localBlobStore struct{ r *localRepository }
+
+func (s *localBlobStore) Stat(ctx distributioncontext.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	data, ok := s.r.blobs[dgst]
+	if !ok {
+		return distribution.Descriptor{}, distribution.ErrBlobUnknown
+	}
+	return distribution.Descriptor{Digest: dgst, Size: int64(len(data)), MediaType: s.r.mediaType[dgst]}, nil
+}
+
+func (s *localBlobStore) Get(ctx distributioncontext.Context, dgst digest.Digest) ([]byte, error) {
+	data, ok := s.r.blobs[dgst]
+	if !ok {
+		return nil, distribution.ErrBlobUnknown
+	}
+	return data, nil
+}
+
+func (s *localBlobStore) Open(ctx distributioncontext.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error) {
+	data, ok := s.r.blobs[dgst]
+	if !ok {
+		return nil, distribution.ErrBlobUnknown
+	}
+	return newBytesReadSeekCloser(data), nil
+}
+
+func (s *localBlobStore) Put(ctx distributioncontext.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	dgst := digest.FromBytes(p)
+	s.r.blobs[dgst] = p
+	s.r.mediaType[dgst] = mediaType
+	if err := s.r.persist(s.r); err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return distribution.Descriptor{Digest: dgst, Size: int64(len(p)), MediaType: mediaType}, nil
+}
+
+func (s *localBlobStore) Create(ctx distributioncontext.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	return &localBlobWriter{s: s}, nil
+}
+
+func (s *localBlobStore) Resume(ctx distributioncontext.Context, id string) (distribution.BlobWriter, error) {
+	return nil, fmt.Errorf("resuming uploads is not supported by this transport")
+}
+
+func (s *localBlobStore) ServeBlob(ctx distributioncontext.Context, w http.ResponseWriter, r *http.Request, dgst digest.Digest) error {
+	return fmt.Errorf("serving blobs is not supported by this transport")
+}
+
+func (s *localBlobStore) Delete(ctx distributioncontext.Context, dgst digest.Digest) error {
+	delete(s.r.blobs, dgst)
+	delete(s.r.mediaType, dgst)
+	return s.r.persist(s.r)
+}
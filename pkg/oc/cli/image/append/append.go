@@ -21,11 +21,13 @@ import (
 	"github.com/docker/distribution"
 	distributioncontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/client"
 	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/kubernetes/pkg/kubectl/cmd/templates"
@@ -34,10 +36,14 @@ import (
 
 	"github.com/openshift/origin/pkg/image/apis/image/docker10"
 	imagereference "github.com/openshift/origin/pkg/image/apis/image/reference"
+	"github.com/openshift/origin/pkg/image/blobinfocache"
 	"github.com/openshift/origin/pkg/image/dockerlayer"
 	"github.com/openshift/origin/pkg/image/dockerlayer/add"
+	"github.com/openshift/origin/pkg/image/dockerlayer/encrypt"
 	"github.com/openshift/origin/pkg/image/registryclient"
 	"github.com/openshift/origin/pkg/image/registryclient/dockercredentials"
+	"github.com/openshift/origin/pkg/image/signature"
+	"github.com/openshift/origin/pkg/image/transport"
 )
 
 var (
@@ -45,14 +51,26 @@ var (
 		Add layers to Docker images
 
 		Modifies an existing image by adding layers or changing configuration and then pushes that
-		image to a remote registry. Any inherited layers are streamed from registry to registry 
-		without being stored locally. The default docker credentials are used for authenticating 
+		image to a remote registry. Any inherited layers are streamed from registry to registry
+		without being stored locally. The default docker credentials are used for authenticating
 		to the registries.
 
-		Layers may be provided as arguments to the command and must each be a gzipped tar archive
-		representing a filesystem overlay to the inherited images. The archive may contain a "whiteout"
-		file (the prefix '.wh.' and the filename) which will hide files in the lower layers. All
-		supported filesystem attributes present in the archive will be used as is.
+		Instead of a registry, --from and --to may each point at a local location by prefixing the
+		value with a transport: docker-archive: for a "docker save" tarball, oci: for an OCI image
+		layout directory, oci-archive: for a tar of one, or dir: for a single manifest and its blobs
+		stored as files in a directory.
+
+		Inherited layers are cached across invocations under $XDG_CACHE_HOME/origin/append/, so
+		repeating a command against the same source image does not re-stream layers whose content
+		ID or destination equivalent is already known, and an upload interrupted partway through is
+		resumed rather than restarted.
+
+		Layers may be provided as arguments to the command and must each be a gzip or zstd compressed
+		tar archive representing a filesystem overlay to the inherited images; the compression of each
+		file is autodetected. The archive may contain a "whiteout" file (the prefix '.wh.' and the
+		filename) which will hide files in the lower layers. All supported filesystem attributes present
+		in the archive will be used as is. Use --compression to recompress provided layers to a specific
+		algorithm before upload; inherited layers are always passed through in their original compression.
 
 		Metadata about the image (the configuration passed to the container runtime) may be altered
 		by passing a JSON string to the --image or --meta options. The --image flag changes what
@@ -65,6 +83,21 @@ var (
 		operating system and architecture unless you use --filter-by-os to select a different image.
 		This flag has no effect on regular images.
 
+		Both Docker schema2 and OCI image manifests and indexes are accepted as the --from image.
+		By default the result is pushed in the Docker schema2 format; pass --output-format=oci to
+		push an OCI image manifest instead.
+
+		New layers may be encrypted for one or more recipients with --encryption-key or
+		--encryption-recipient (jwe: followed by the recipient's RSA public key; pgp: and pkcs7:
+		are recognized but not yet implemented). Inherited layers that are already encrypted
+		require --decryption-key to calculate their content ID; the ciphertext itself is passed
+		through to the destination unchanged.
+
+		The pushed manifest may be signed with --sign-by=<gpg fingerprint> (a simple signing
+		signature, published to --signature-store or the registry's signature extension) and/or
+		--sign-by-sigstore=<keyfile> (a cosign-style signature published as a tag on the destination
+		repository).
+
 		Experimental: This command is under active development and may change without notice.`)
 
 	example = templates.Examples(`
@@ -91,6 +124,20 @@ type AppendImageOptions struct {
 
 	FilterByOS string
 
+	OutputFormat string
+	Compression  string
+
+	EncryptionKeys       []string
+	EncryptionRecipients []string
+	DecryptionKeys       []string
+
+	encryptConfig *encrypt.EncryptConfig
+	decryptConfig *encrypt.DecryptConfig
+
+	SignBy         string
+	SignBySigstore string
+	SignatureStore string
+
 	MaxPerRegistry int
 
 	DryRun   bool
@@ -100,16 +147,20 @@ type AppendImageOptions struct {
 	genericclioptions.IOStreams
 }
 
-// schema2ManifestOnly specifically requests a manifest list first
+// schema2ManifestOnly specifically requests a manifest list or image index
+// first, then falls back to the Docker and OCI single-manifest media types.
 var schema2ManifestOnly = distribution.WithManifestMediaTypes([]string{
 	manifestlist.MediaTypeManifestList,
+	manifestlist.MediaTypeOCIImageIndex,
 	schema2.MediaTypeManifest,
+	ocischema.MediaTypeImageManifest,
 })
 
 func NewAppendImageOptions(streams genericclioptions.IOStreams) *AppendImageOptions {
 	return &AppendImageOptions{
 		IOStreams:      streams,
 		MaxPerRegistry: 3,
+		OutputFormat:   "docker",
 	}
 }
 
@@ -143,11 +194,47 @@ func NewCmdAppendImage(name string, streams genericclioptions.IOStreams) *cobra.
 
 	flag.BoolVar(&o.Force, "force", o.Force, "If set, the command will attempt to upload all layers instead of skipping those that are already uploaded.")
 	flag.IntVar(&o.MaxPerRegistry, "max-per-registry", o.MaxPerRegistry, "Number of concurrent requests allowed per registry.")
+	flag.StringVar(&o.OutputFormat, "output-format", o.OutputFormat, "The manifest schema to push the appended image as: docker or oci.")
+	flag.StringVar(&o.Compression, "compression", o.Compression, "If set, recompress the layer tar archives given as arguments with this algorithm before uploading: gzip or zstd.")
+
+	flag.StringArrayVar(&o.EncryptionKeys, "encryption-key", o.EncryptionKeys, "A recipient to encrypt new layers for, of the form <protocol>:<value>. Only the jwe protocol is currently implemented. May be repeated.")
+	flag.StringArrayVar(&o.EncryptionRecipients, "encryption-recipient", o.EncryptionRecipients, "Alias for --encryption-key.")
+	flag.StringArrayVar(&o.DecryptionKeys, "decryption-key", o.DecryptionKeys, "A private key file used to decrypt inherited encrypted layers. May be repeated.")
+
+	flag.StringVar(&o.SignBy, "sign-by", o.SignBy, "Sign the pushed manifest with the named GPG key fingerprint, producing a simple signing signature.")
+	flag.StringVar(&o.SignBySigstore, "sign-by-sigstore", o.SignBySigstore, "Sign the pushed manifest with the ECDSA private key file, publishing a cosign-style signature tag.")
+	flag.StringVar(&o.SignatureStore, "signature-store", o.SignatureStore, "Where to publish --sign-by signatures: a file:// or http(s):// lookaside location. Defaults to the registry's signature extension endpoint.")
 
 	return cmd
 }
 
 func (o *AppendImageOptions) Complete(cmd *cobra.Command, args []string) error {
+	switch o.OutputFormat {
+	case "docker", "oci":
+	default:
+		return fmt.Errorf("--output-format must be 'docker' or 'oci'")
+	}
+	switch o.Compression {
+	case "", "gzip", "zstd":
+	default:
+		return fmt.Errorf("--compression must be 'gzip' or 'zstd'")
+	}
+
+	if recipients := append(append([]string{}, o.EncryptionKeys...), o.EncryptionRecipients...); len(recipients) > 0 {
+		ec, err := encrypt.ParseRecipients(recipients)
+		if err != nil {
+			return err
+		}
+		o.encryptConfig = ec
+	}
+	if len(o.DecryptionKeys) > 0 {
+		dc, err := encrypt.ParseDecryptionKeys(o.DecryptionKeys)
+		if err != nil {
+			return err
+		}
+		o.decryptConfig = dc
+	}
+
 	pattern := o.FilterByOS
 	if len(pattern) == 0 && !cmd.Flags().Changed("filter-by-os") {
 		o.DefaultOSFilter = true
@@ -175,6 +262,19 @@ func (o *AppendImageOptions) Complete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// decryptForDiffID returns a reader over the plaintext (but still compressed)
+// contents of an inherited layer, decrypting it first if layer is encrypted.
+func (o *AppendImageOptions) decryptForDiffID(layer distribution.Descriptor, r io.Reader) (io.Reader, error) {
+	if !encrypt.IsEncrypted(layer.MediaType) {
+		return r, nil
+	}
+	plaintext, err := encrypt.DecryptLayer(o.decryptConfig, r, layer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt layer %s: %v", layer.Digest, err)
+	}
+	return plaintext, nil
+}
+
 // includeDescriptor returns true if the provided manifest should be included.
 func (o *AppendImageOptions) includeDescriptor(d *manifestlist.ManifestDescriptor, hasMultiple bool) bool {
 	if o.OSFilter == nil {
@@ -210,7 +310,10 @@ func (o *AppendImageOptions) Run() error {
 		if err != nil {
 			return err
 		}
-		if len(src.Tag) == 0 && len(src.ID) == 0 {
+		// Non-registry transports (docker-archive:, oci:, oci-archive:, dir:) name a single
+		// location on disk rather than an image within a repository, so they have no tag or ID
+		// to require.
+		if src.Transport == imagereference.TransportDocker && len(src.Tag) == 0 && len(src.ID) == 0 {
 			return fmt.Errorf("--from must point to an image ID or image tag")
 		}
 		from = &src
@@ -219,7 +322,7 @@ func (o *AppendImageOptions) Run() error {
 	if err != nil {
 		return err
 	}
-	if len(to.ID) > 0 {
+	if to.Transport == imagereference.TransportDocker && len(to.ID) > 0 {
 		return fmt.Errorf("--to may not point to an image by ID")
 	}
 
@@ -236,7 +339,12 @@ func (o *AppendImageOptions) Run() error {
 	fromContext := registryclient.NewContext(rt, insecureRT).WithCredentials(creds)
 	toContext := registryclient.NewContext(rt, insecureRT).WithActions("push").WithCredentials(creds)
 
-	toRepo, err := toContext.Repository(ctx, to.DockerClientDefaults().RegistryURL(), to.RepositoryName(), o.Insecure)
+	blobCache, err := blobinfocache.Open()
+	if err != nil {
+		return fmt.Errorf("unable to open the layer info cache: %v", err)
+	}
+
+	toRepo, err := transport.NewRepository(ctx, to, toContext, o.Insecure)
 	if err != nil {
 		return err
 	}
@@ -251,20 +359,33 @@ func (o *AppendImageOptions) Run() error {
 		fromRepo distribution.Repository
 	)
 	if from != nil {
-		repo, err := fromContext.Repository(ctx, from.DockerClientDefaults().RegistryURL(), from.RepositoryName(), o.Insecure)
+		repo, err := transport.NewRepository(ctx, *from, fromContext, o.Insecure)
 		if err != nil {
 			return err
 		}
 		fromRepo = repo
 		var srcDigest digest.Digest
-		if len(from.Tag) > 0 {
+		switch {
+		case len(from.Tag) > 0:
 			desc, err := repo.Tags(ctx).Get(ctx, from.Tag)
 			if err != nil {
 				return err
 			}
 			srcDigest = desc.Digest
-		} else {
+		case len(from.ID) > 0:
 			srcDigest = digest.Digest(from.ID)
+		default:
+			// Non-registry transports that were not given a tag name the single image
+			// they hold; resolve it by looking at whatever got tagged on load.
+			tags, err := repo.Tags(ctx).All(ctx)
+			if err != nil || len(tags) == 0 {
+				return fmt.Errorf("%s does not contain a taggable image", from.String())
+			}
+			desc, err := repo.Tags(ctx).Get(ctx, tags[0])
+			if err != nil {
+				return err
+			}
+			srcDigest = desc.Digest
 		}
 		manifests, err := repo.Manifests(ctx)
 		if err != nil {
@@ -313,6 +434,32 @@ func (o *AppendImageOptions) Run() error {
 				base.Size += layer.Size
 			}
 
+		case *ocischema.DeserializedManifest:
+			if t.Config.MediaType != ocischema.MediaTypeImageConfig {
+				return fmt.Errorf("unable to append layers to images with config %s from %s", t.Config.MediaType, location)
+			}
+			configJSON, err := repo.Blobs(ctx).Get(ctx, t.Config.Digest)
+			if err != nil {
+				return fmt.Errorf("unable to find manifest for image %s: %v", *from, err)
+			}
+			glog.V(4).Infof("Raw image config json:\n%s", string(configJSON))
+			ociConfig := &ocispec.Image{}
+			if err := json.Unmarshal(configJSON, &ociConfig); err != nil {
+				return fmt.Errorf("the source image manifest could not be parsed: %v", err)
+			}
+
+			config := &docker10.DockerImageConfig{}
+			if err := docker10.Convert_OCI_Image_Config_to_DockerImageConfig(ociConfig, config); err != nil {
+				return err
+			}
+
+			base = config
+			layers = t.Layers
+			base.Size = 0
+			for _, layer := range t.Layers {
+				base.Size += layer.Size
+			}
+
 		case *schema1.SignedManifest:
 			if glog.V(4) {
 				_, configJSON, _ := srcManifest.Payload()
@@ -396,6 +543,19 @@ func (o *AppendImageOptions) Run() error {
 				return err
 			}
 			defer f.Close()
+
+			var r io.Reader = f
+			if len(o.Compression) > 0 {
+				if r, err = dockerlayer.Recompress(dockerlayer.Compression(o.Compression), f); err != nil {
+					return fmt.Errorf("unable to recompress layer %s: %v", arg, err)
+				}
+			}
+			compression, peeked, err := dockerlayer.DetectCompression(r)
+			if err != nil {
+				return fmt.Errorf("unable to detect compression of layer %s: %v", arg, err)
+			}
+			r = peeked
+
 			var readerFrom io.ReaderFrom = ioutil.Discard.(io.ReaderFrom)
 			var done = func(distribution.Descriptor) error { return nil }
 			if !o.DryRun {
@@ -418,17 +578,57 @@ func (o *AppendImageOptions) Run() error {
 					return nil
 				}
 			}
-			layerDigest, blobDigest, modTime, n, err := add.DigestCopy(readerFrom, f)
-			desc := distribution.Descriptor{
-				Digest:    blobDigest,
-				Size:      n,
-				MediaType: schema2.MediaTypeLayer,
+
+			mediaType := dockerlayer.MediaTypeForCompression(compression, false)
+			var layerDigest digest.Digest
+			var desc distribution.Descriptor
+			if o.encryptConfig != nil {
+				// Encryption changes the bytes that must be uploaded, so the
+				// diffID (over the plaintext layer) has to be calculated
+				// separately from the blob digest (over the ciphertext). This
+				// buffers the layer in memory to allow two passes.
+				plaintext, err := ioutil.ReadAll(r)
+				if err != nil {
+					return fmt.Errorf("unable to read layer %s: %v", arg, err)
+				}
+				layerDigest, _, _, _, err = add.DigestCopy(ioutil.Discard.(io.ReaderFrom), bytes.NewReader(plaintext))
+				if err != nil {
+					return fmt.Errorf("unable to calculate contentID for layer %s: %v", arg, err)
+				}
+				ciphertext, annotations, err := encrypt.EncryptLayer(o.encryptConfig, bytes.NewReader(plaintext))
+				if err != nil {
+					return fmt.Errorf("unable to encrypt layer %s: %v", arg, err)
+				}
+				blobDigester := digest.Canonical.Digester()
+				n, err := readerFrom.ReadFrom(io.TeeReader(ciphertext, blobDigester.Hash()))
+				if err != nil {
+					return fmt.Errorf("unable to upload encrypted layer %s: %v", arg, err)
+				}
+				desc = distribution.Descriptor{
+					Digest:      blobDigester.Digest(),
+					Size:        n,
+					MediaType:   encrypt.MediaType(add.OCILayerMediaType(mediaType)),
+					Annotations: annotations,
+				}
+			} else {
+				var blobDigest digest.Digest
+				var modTime *time.Time
+				var n int64
+				layerDigest, blobDigest, modTime, n, err = add.DigestCopy(readerFrom, r)
+				if err != nil {
+					return err
+				}
+				desc = distribution.Descriptor{
+					Digest:    blobDigest,
+					Size:      n,
+					MediaType: mediaType,
+				}
+				if modTime != nil && !modTime.IsZero() {
+					base.Created = *modTime
+				}
 			}
 			layers = append(layers, desc)
 			add.AddLayerToConfig(base, desc, layerDigest.String())
-			if modTime != nil && !modTime.IsZero() {
-				base.Created = *modTime
-			}
 			return done(desc)
 		}()
 		if err != nil {
@@ -454,14 +654,32 @@ func (o *AppendImageOptions) Run() error {
 			w.Try(func() error {
 				fromBlobs := fromRepo.Blobs(ctx)
 
+				// a previous run may already have learned this layer's content ID,
+				// sparing us from streaming it just to recompute that
+				cached, haveCached := blobCache.Lookup(layer.Digest)
+				if missingDiffID && haveCached && len(cached.DiffID) > 0 {
+					glog.V(4).Infof("Layer %s has cached tar sum %s", layer.Digest, cached.DiffID)
+					base.RootFS.DiffIDs[index] = cached.DiffID
+					missingDiffID = false
+				}
+
+				// a previously recorded destination equivalent (e.g. a layer that was
+				// recompressed on a prior push) lets us check for existence under the
+				// digest actually present at the destination
+				statDigest := layer.Digest
+				if haveCached && len(cached.DstDigest) > 0 {
+					statDigest = cached.DstDigest
+				}
+
 				// check whether the blob exists
 				if !o.Force {
-					if desc, err := toBlobs.Stat(ctx, layer.Digest); err == nil {
+					if desc, err := toBlobs.Stat(ctx, statDigest); err == nil {
 						// ensure the correct size makes it back to the manifest
-						glog.V(4).Infof("Layer %s already exists in destination (%s)", layer.Digest, units.HumanSizeWithPrecision(float64(layer.Size), 3))
+						glog.V(4).Infof("Layer %s already exists in destination (%s)", statDigest, units.HumanSizeWithPrecision(float64(layer.Size), 3))
 						if layer.Size == 0 {
 							layer.Size = desc.Size
 						}
+						layer.Digest = statDigest
 						// we need to calculate the tar sum from the image, requiring us to pull it
 						if missingDiffID {
 							glog.V(4).Infof("Need tar sum, streaming layer %s", layer.Digest)
@@ -470,7 +688,11 @@ func (o *AppendImageOptions) Run() error {
 								return fmt.Errorf("unable to access the layer %s in order to calculate its content ID: %v", layer.Digest, err)
 							}
 							defer r.Close()
-							layerDigest, _, _, _, err := add.DigestCopy(ioutil.Discard.(io.ReaderFrom), r)
+							plaintext, err := o.decryptForDiffID(*layer, r)
+							if err != nil {
+								return err
+							}
+							layerDigest, _, _, _, err := add.DigestCopy(ioutil.Discard.(io.ReaderFrom), plaintext)
 							if err != nil {
 								return fmt.Errorf("unable to calculate contentID for layer %s: %v", layer.Digest, err)
 							}
@@ -480,32 +702,42 @@ func (o *AppendImageOptions) Run() error {
 						// TODO: due to a bug in the registry, the empty layer is always returned as existing, but
 						// an upload without it will fail - https://bugzilla.redhat.com/show_bug.cgi?id=1599028
 						if layer.Digest != dockerlayer.GzippedEmptyLayerDigest {
-							return nil
+							return blobCache.Record(layer.Digest, blobinfocache.Entry{
+								DstDigest:   desc.Digest,
+								Size:        desc.Size,
+								Compression: compressionForMediaType(layer.MediaType),
+								DiffID:      base.RootFS.DiffIDs[index],
+							})
 						}
 					}
 				}
 
-				// source
-				r, err := fromBlobs.Open(ctx, layer.Digest)
-				if err != nil {
-					return fmt.Errorf("unable to access the source layer %s: %v", layer.Digest, err)
-				}
-				defer r.Close()
-
 				// destination
 				mountOptions := []distribution.BlobCreateOption{WithDescriptor(*layer)}
-				if from != nil && from.Registry == to.Registry {
+				if from != nil && from.Transport == imagereference.TransportDocker && to.Transport == imagereference.TransportDocker && from.Registry == to.Registry {
 					source, err := reference.WithDigest(fromRepo.Named(), layer.Digest)
 					if err != nil {
 						return err
 					}
 					mountOptions = append(mountOptions, client.WithMountFrom(source))
 				}
-				bw, err := toBlobs.Create(ctx, mountOptions...)
+				bw, err := resumeOrCreate(ctx, toBlobs, blobCache, layer.Digest, mountOptions...)
 				if err != nil {
 					return fmt.Errorf("unable to upload layer %s to destination repository: %v", layer.Digest, err)
 				}
 				defer bw.Close()
+				pending := cached
+				pending.PendingUploadID = bw.ID()
+				if err := blobCache.Record(layer.Digest, pending); err != nil {
+					glog.V(4).Infof("Unable to persist resumable upload state for %s: %v", layer.Digest, err)
+				}
+				// a resumed writer already has this many bytes on the
+				// registry side; every copy path below must skip past them
+				// instead of writing the source from the start again.
+				skip := bw.Size()
+				if skip > 0 {
+					glog.V(4).Infof("Resuming upload of %s from byte %d", layer.Digest, skip)
+				}
 
 				// copy the blob, calculating the diffID if necessary
 				if layer.Size > 0 {
@@ -515,14 +747,54 @@ func (o *AppendImageOptions) Run() error {
 				}
 				if missingDiffID {
 					glog.V(4).Infof("Need tar sum, calculating while streaming %s", layer.Digest)
-					layerDigest, _, _, _, err := add.DigestCopy(bw, r)
+					r, err := fromBlobs.Open(ctx, layer.Digest)
 					if err != nil {
-						return err
+						return fmt.Errorf("unable to access the source layer %s: %v", layer.Digest, err)
+					}
+					defer r.Close()
+					// Inherited ciphertext is always passed through to the destination
+					// unchanged (TODO: re-encrypt for a different recipient set on push);
+					// the diffID still has to be calculated from the plaintext, so when the
+					// layer is encrypted it is buffered once to decrypt a copy for hashing
+					// while the original bytes are streamed to bw untouched.
+					if encrypt.IsEncrypted(layer.MediaType) {
+						ciphertext, err := ioutil.ReadAll(r)
+						if err != nil {
+							return fmt.Errorf("unable to read encrypted layer %s: %v", layer.Digest, err)
+						}
+						plaintext, err := o.decryptForDiffID(*layer, bytes.NewReader(ciphertext))
+						if err != nil {
+							return err
+						}
+						layerDigest, _, _, _, err := add.DigestCopy(ioutil.Discard.(io.ReaderFrom), plaintext)
+						if err != nil {
+							return fmt.Errorf("unable to calculate contentID for layer %s: %v", layer.Digest, err)
+						}
+						base.RootFS.DiffIDs[index] = layerDigest.String()
+						remaining := ciphertext
+						if skip > 0 {
+							if skip > int64(len(remaining)) {
+								skip = int64(len(remaining))
+							}
+							remaining = remaining[skip:]
+						}
+						if _, err := bw.ReadFrom(bytes.NewReader(remaining)); err != nil {
+							return fmt.Errorf("unable to copy the source layer %s to the destination image: %v", layer.Digest, err)
+						}
+					} else {
+						layerDigest, _, _, _, err := add.DigestCopy(&skipReadFrom{dst: bw, skip: skip}, r)
+						if err != nil {
+							return err
+						}
+						glog.V(4).Infof("Layer %s has tar sum %s", layer.Digest, layerDigest)
+						base.RootFS.DiffIDs[index] = layerDigest.String()
 					}
-					glog.V(4).Infof("Layer %s has tar sum %s", layer.Digest, layerDigest)
-					base.RootFS.DiffIDs[index] = layerDigest.String()
 				} else {
-					if _, err := bw.ReadFrom(r); err != nil {
+					// the content ID is already known, so chunks can be fetched and
+					// written out of order relative to one another; split the copy
+					// across up to --max-per-registry concurrent range reads.
+					open := func() (distribution.ReadSeekCloser, error) { return fromBlobs.Open(ctx, layer.Digest) }
+					if _, err := chunkedCopy(open, bw, layer.Size, o.MaxPerRegistry, skip); err != nil {
 						return fmt.Errorf("unable to copy the source layer %s to the destination image: %v", layer.Digest, err)
 					}
 				}
@@ -539,7 +811,12 @@ func (o *AppendImageOptions) Run() error {
 				if layer.Size == 0 {
 					layer.Size = desc.Size
 				}
-				return nil
+				return blobCache.Record(layer.Digest, blobinfocache.Entry{
+					DstDigest:   desc.Digest,
+					Size:        desc.Size,
+					Compression: compressionForMediaType(layer.MediaType),
+					DiffID:      base.RootFS.DiffIDs[index],
+				})
 			})
 		}
 	})
@@ -547,7 +824,12 @@ func (o *AppendImageOptions) Run() error {
 		return err
 	}
 
-	manifest, err := add.UploadSchema2Config(ctx, toBlobs, base, layers)
+	var manifest distribution.Manifest
+	if o.OutputFormat == "oci" {
+		manifest, err = add.UploadOCIManifest(ctx, toBlobs, base, layers)
+	} else {
+		manifest, err = add.UploadSchema2Config(ctx, toBlobs, base, layers)
+	}
 	if err != nil {
 		return fmt.Errorf("unable to upload the new image manifest: %v", err)
 	}
@@ -556,6 +838,59 @@ func (o *AppendImageOptions) Run() error {
 		return fmt.Errorf("unable to convert the image to a compatible schema version: %v", err)
 	}
 	fmt.Fprintf(o.Out, "Pushed image %s to %s\n", toDigest, to)
+
+	if err := o.sign(ctx, to, toDigest, toManifests, toBlobs, rt, insecureRT); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sign publishes the signatures requested by --sign-by and --sign-by-sigstore
+// over the just-pushed manifest identified by toDigest. transport and
+// insecureTransport mirror the ones used to push the manifest itself, so the
+// signature store is reached the same way (plain HTTP when --insecure named
+// this registry, HTTPS otherwise).
+func (o *AppendImageOptions) sign(ctx context.Context, to imagereference.DockerImageReference, toDigest digest.Digest, toManifests distribution.ManifestService, toBlobs distribution.BlobService, transport, insecureTransport http.RoundTripper) error {
+	if len(o.SignBy) > 0 {
+		payload, err := signature.NewAtomicSignaturePayload(to.String(), toDigest)
+		if err != nil {
+			return err
+		}
+		sig, err := signature.SignWithGPG(ctx, o.SignBy, payload)
+		if err != nil {
+			return fmt.Errorf("unable to sign manifest with --sign-by: %v", err)
+		}
+		scheme := "https"
+		rt := transport
+		if o.Insecure {
+			scheme = "http"
+			rt = insecureTransport
+		}
+		store, err := signature.NewStore(o.SignatureStore, &http.Client{Transport: rt}, scheme+"://"+to.Registry, to.RepositoryName())
+		if err != nil {
+			return err
+		}
+		if err := store.Put(ctx, toDigest, sig); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.Out, "Signed %s with key %s\n", toDigest, o.SignBy)
+	}
+
+	if len(o.SignBySigstore) > 0 {
+		keyfile, err := ioutil.ReadFile(o.SignBySigstore)
+		if err != nil {
+			return fmt.Errorf("unable to read --sign-by-sigstore key: %v", err)
+		}
+		sig, err := signature.SignWithSigstoreKey(keyfile, []byte(toDigest.String()))
+		if err != nil {
+			return fmt.Errorf("unable to sign manifest with --sign-by-sigstore: %v", err)
+		}
+		sigDigest, err := signature.PushSigstoreSignature(ctx, toBlobs, toManifests, toDigest, sig)
+		if err != nil {
+			return fmt.Errorf("unable to publish sigstore signature: %v", err)
+		}
+		fmt.Fprintf(o.Out, "Signed %s with sigstore, signature manifest %s tagged %s\n", toDigest, sigDigest, signature.SigstoreTag(toDigest))
+	}
 	return nil
 }
 
@@ -0,0 +1,156 @@
+package append
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution"
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/openshift/origin/pkg/image/blobinfocache"
+)
+
+// chunkedCopySize is the size of each concurrently-fetched range when a
+// layer's source supports ranged reads, chosen to balance the number of
+// concurrent connections a single layer opens against per-request overhead.
+const chunkedCopySize = 32 * 1024 * 1024
+
+// chunkedCopy copies the portion of size bytes starting at skip into dst,
+// using up to concurrency concurrent range reads against a source opened by
+// open, then writing the results to dst in order. skip is non-zero when dst
+// is a BlobWriter resumed part-way through a previous attempt (its Size()),
+// so the bytes it already has are neither re-fetched nor re-written. It
+// falls back to a single linear copy when the remaining size is unknown, too
+// small to be worth splitting, or concurrency is 1, and is only suitable for
+// copies where the destination digest is already known (it cannot also
+// compute a content ID, since chunks complete out of order).
+func chunkedCopy(open func() (distribution.ReadSeekCloser, error), dst io.Writer, size int64, concurrency int, skip int64) (int64, error) {
+	if size > 0 && skip >= size {
+		return 0, nil
+	}
+	if size <= 0 || size-skip <= chunkedCopySize || concurrency <= 1 {
+		r, err := open()
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+		if skip > 0 {
+			if _, err := r.Seek(skip, io.SeekStart); err != nil {
+				return 0, err
+			}
+		}
+		return io.Copy(dst, r)
+	}
+
+	type byteRange struct {
+		offset, length int64
+	}
+	var ranges []byteRange
+	for offset := skip; offset < size; offset += chunkedCopySize {
+		length := int64(chunkedCopySize)
+		if offset+length > size {
+			length = size - offset
+		}
+		ranges = append(ranges, byteRange{offset: offset, length: length})
+	}
+
+	chunks := make([][]byte, len(ranges))
+	errs := make([]error, len(ranges))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, br := range ranges {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, br byteRange) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r, err := open()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer r.Close()
+			if _, err := r.Seek(br.offset, io.SeekStart); err != nil {
+				errs[i] = err
+				return
+			}
+			buf := make([]byte, br.length)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				errs[i] = err
+				return
+			}
+			chunks[i] = buf
+		}(i, br)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var n int64
+	for _, buf := range chunks {
+		written, err := dst.Write(buf)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// resumeOrCreate resumes a previously interrupted upload for srcDigest if the
+// cache has a pending upload ID recorded for it, falling back to starting a
+// new upload when there is none or the registry no longer recognizes it.
+func resumeOrCreate(ctx context.Context, toBlobs distribution.BlobStore, cache *blobinfocache.Cache, srcDigest digest.Digest, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	if cached, ok := cache.Lookup(srcDigest); ok && len(cached.PendingUploadID) > 0 {
+		if bw, err := toBlobs.Resume(ctx, cached.PendingUploadID); err == nil {
+			return bw, nil
+		}
+	}
+	return toBlobs.Create(ctx, options...)
+}
+
+// skipReadFrom wraps a destination that implements io.ReaderFrom so the
+// first skip bytes read from the source are discarded rather than written,
+// matching the offset a resumed BlobWriter (dst.Size()) already has on the
+// registry side. The returned count still covers the full source read, skip
+// included, since callers such as add.DigestCopy use it to size the blob
+// descriptor.
+type skipReadFrom struct {
+	dst  io.ReaderFrom
+	skip int64
+}
+
+func (s *skipReadFrom) ReadFrom(r io.Reader) (int64, error) {
+	if s.skip > 0 {
+		n, err := io.CopyN(ioutil.Discard, r, s.skip)
+		if err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+	}
+	written, err := s.dst.ReadFrom(r)
+	return s.skip + written, err
+}
+
+// compressionForMediaType returns the --compression value ("gzip" or "zstd")
+// implied by a layer media type, or "" if it cannot be determined.
+func compressionForMediaType(mediaType string) string {
+	switch {
+	case strings.Contains(mediaType, "zstd"):
+		return "zstd"
+	case strings.Contains(mediaType, "gzip"):
+		return "gzip"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,221 @@
+package append
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/openshift/origin/pkg/image/blobinfocache"
+)
+
+// fakeReadSeekCloser adapts a bytes.Reader to distribution.ReadSeekCloser for
+// tests that only need Seek/Read/Close, not a real blob source.
+type fakeReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (fakeReadSeekCloser) Close() error { return nil }
+
+func TestChunkedCopy(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), chunkedCopySize/10+1) // > chunkedCopySize
+	open := func() (distribution.ReadSeekCloser, error) {
+		return fakeReadSeekCloser{bytes.NewReader(content)}, nil
+	}
+
+	var dst bytes.Buffer
+	n, err := chunkedCopy(open, &dst, int64(len(content)), 4, 0)
+	if err != nil {
+		t.Fatalf("chunkedCopy: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Fatalf("chunkedCopy returned n=%d, expected %d", n, len(content))
+	}
+	if !bytes.Equal(dst.Bytes(), content) {
+		t.Fatalf("chunkedCopy wrote %d bytes that do not match the source", dst.Len())
+	}
+}
+
+func TestChunkedCopySkipsAlreadyUploadedBytes(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 1024)
+	const skip = 2048
+
+	open := func() (distribution.ReadSeekCloser, error) {
+		return fakeReadSeekCloser{bytes.NewReader(content)}, nil
+	}
+
+	var dst bytes.Buffer
+	n, err := chunkedCopy(open, &dst, int64(len(content)), 1, skip)
+	if err != nil {
+		t.Fatalf("chunkedCopy: %v", err)
+	}
+	if n != int64(len(content))-skip {
+		t.Fatalf("chunkedCopy returned n=%d, expected %d", n, len(content)-skip)
+	}
+	if !bytes.Equal(dst.Bytes(), content[skip:]) {
+		t.Fatalf("chunkedCopy did not skip the first %d bytes of the source", skip)
+	}
+}
+
+func TestChunkedCopySkipAtOrBeyondSize(t *testing.T) {
+	content := []byte("short")
+	open := func() (distribution.ReadSeekCloser, error) {
+		return fakeReadSeekCloser{bytes.NewReader(content)}, nil
+	}
+	var dst bytes.Buffer
+	n, err := chunkedCopy(open, &dst, int64(len(content)), 4, int64(len(content)))
+	if err != nil {
+		t.Fatalf("chunkedCopy: %v", err)
+	}
+	if n != 0 || dst.Len() != 0 {
+		t.Fatalf("chunkedCopy with skip >= size should write nothing, wrote %d bytes", dst.Len())
+	}
+}
+
+func TestSkipReadFrom(t *testing.T) {
+	var dst bytes.Buffer
+	s := &skipReadFrom{dst: readerFromWriter{&dst}, skip: 4}
+	n, err := s.ReadFrom(bytes.NewReader([]byte("0123456789")))
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("ReadFrom returned n=%d, expected 10 (the full source length, skip included)", n)
+	}
+	if dst.String() != "456789" {
+		t.Fatalf("ReadFrom wrote %q, expected %q", dst.String(), "456789")
+	}
+}
+
+// readerFromWriter adapts an io.Writer to io.ReaderFrom using io.Copy, for
+// tests that only need to observe what skipReadFrom forwards.
+type readerFromWriter struct {
+	w io.Writer
+}
+
+func (r readerFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(r.w, src)
+}
+
+// fakeBlobWriter is a minimal distribution.BlobWriter that records what was
+// written to it and reports a fixed Size(), simulating a writer returned by
+// Resume() for an upload that already has some bytes on the registry side.
+type fakeBlobWriter struct {
+	id        string
+	size      int64
+	startedAt time.Time
+	buf       bytes.Buffer
+}
+
+func (w *fakeBlobWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+func (w *fakeBlobWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := w.buf.ReadFrom(r)
+	w.size += n
+	return n, err
+}
+func (w *fakeBlobWriter) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+func (w *fakeBlobWriter) Close() error                     { return nil }
+func (w *fakeBlobWriter) ID() string                       { return w.id }
+func (w *fakeBlobWriter) StartedAt() time.Time             { return w.startedAt }
+func (w *fakeBlobWriter) Size() int64                      { return w.size }
+func (w *fakeBlobWriter) Cancel(ctx context.Context) error { return nil }
+func (w *fakeBlobWriter) Commit(ctx context.Context, provisional distribution.Descriptor) (distribution.Descriptor, error) {
+	return provisional, nil
+}
+
+// fakeBlobStore implements distribution.BlobStore just enough to exercise
+// resumeOrCreate: Resume succeeds only for a single known pending ID, and
+// Create always returns a fresh writer.
+type fakeBlobStore struct {
+	pendingID string
+	resumed   *fakeBlobWriter
+	created   int
+}
+
+func (s *fakeBlobStore) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, errors.New("not implemented")
+}
+func (s *fakeBlobStore) Get(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+func (s *fakeBlobStore) Open(ctx context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error) {
+	return nil, errors.New("not implemented")
+}
+func (s *fakeBlobStore) Put(ctx context.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, errors.New("not implemented")
+}
+func (s *fakeBlobStore) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	s.created++
+	return &fakeBlobWriter{id: "new-upload"}, nil
+}
+func (s *fakeBlobStore) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
+	if id == s.pendingID && s.resumed != nil {
+		return s.resumed, nil
+	}
+	return nil, errors.New("unknown upload")
+}
+
+func TestResumeOrCreateResumesKnownUpload(t *testing.T) {
+	srcDigest := digest.FromString("layer-1")
+	cache := newMemoryCache(t)
+	if err := cache.Record(srcDigest, blobinfocache.Entry{PendingUploadID: "upload-123"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	store := &fakeBlobStore{pendingID: "upload-123", resumed: &fakeBlobWriter{id: "upload-123", size: 1024}}
+	bw, err := resumeOrCreate(context.Background(), store, cache, srcDigest)
+	if err != nil {
+		t.Fatalf("resumeOrCreate: %v", err)
+	}
+	if bw.ID() != "upload-123" {
+		t.Fatalf("resumeOrCreate returned writer %q, expected the resumed upload", bw.ID())
+	}
+	if store.created != 0 {
+		t.Fatalf("resumeOrCreate called Create, expected it to resume instead")
+	}
+}
+
+func TestResumeOrCreateFallsBackWhenUnknown(t *testing.T) {
+	srcDigest := digest.FromString("layer-1")
+	cache := newMemoryCache(t)
+	if err := cache.Record(srcDigest, blobinfocache.Entry{PendingUploadID: "stale-upload"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	store := &fakeBlobStore{} // Resume always fails: no matching pendingID
+	bw, err := resumeOrCreate(context.Background(), store, cache, srcDigest)
+	if err != nil {
+		t.Fatalf("resumeOrCreate: %v", err)
+	}
+	if bw.ID() != "new-upload" {
+		t.Fatalf("resumeOrCreate returned writer %q, expected a newly created upload", bw.ID())
+	}
+	if store.created != 1 {
+		t.Fatalf("resumeOrCreate did not call Create after a failed Resume")
+	}
+}
+
+// newMemoryCache returns a blobinfocache.Cache backed by a file under the
+// test's temporary directory, since the cache only knows how to persist to
+// disk.
+func newMemoryCache(t *testing.T) *blobinfocache.Cache {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	cache, err := blobinfocache.Open()
+	if err != nil {
+		t.Fatalf("blobinfocache.Open: %v", err)
+	}
+	return cache
+}
@@ -0,0 +1,81 @@
+package append
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+
+	imagereference "github.com/openshift/origin/pkg/image/apis/image/reference"
+)
+
+// processManifestList inspects srcManifest and, if it is a manifest list or
+// OCI image index (the two share a wire format, distinguished only by
+// MediaType), filters its entries with include and resolves the first
+// remaining entry to a concrete manifest. Non-list manifests are returned
+// unchanged. The returned descriptors are the entries that survived
+// filtering (for list/index inputs) or a single synthetic descriptor for the
+// resolved manifest (for non-list inputs), so callers can detect "everything
+// was filtered out".
+func processManifestList(
+	ctx context.Context,
+	srcDigest digest.Digest,
+	srcManifest distribution.Manifest,
+	manifests distribution.ManifestService,
+	from imagereference.DockerImageReference,
+	include func(*manifestlist.ManifestDescriptor, bool) bool,
+) ([]manifestlist.ManifestDescriptor, distribution.Manifest, digest.Digest, error) {
+	list, ok := srcManifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		return []manifestlist.ManifestDescriptor{{Descriptor: distribution.Descriptor{Digest: srcDigest}}}, srcManifest, srcDigest, nil
+	}
+
+	hasMultiple := len(list.Manifests) > 1
+	var filtered []manifestlist.ManifestDescriptor
+	for i := range list.Manifests {
+		d := list.Manifests[i]
+		if include(&d, hasMultiple) {
+			filtered = append(filtered, d)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, nil, "", nil
+	}
+
+	chosen := filtered[0]
+	manifest, err := manifests.Get(ctx, chosen.Digest, schema2ManifestOnly)
+	kind := "manifest list"
+	if list.MediaType == manifestlist.MediaTypeManifestList {
+		kind = "manifest list"
+	} else {
+		kind = "image index"
+	}
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to retrieve image %s from %s for %s: %v", chosen.Digest, kind, from, err)
+	}
+	return filtered, manifest, chosen.Digest, nil
+}
+
+// putManifestInCompatibleSchema pushes manifest to toManifests under tag. If
+// manifest was retrieved in a schema that isn't understood by the
+// destination, callers are expected to have already converted it to schema2
+// or an OCI manifest before invoking this function; fromBlobs is accepted so
+// a future conversion step can resolve config blobs without an additional
+// round trip to the source repository.
+func putManifestInCompatibleSchema(
+	ctx context.Context,
+	manifest distribution.Manifest,
+	tag string,
+	toManifests distribution.ManifestService,
+	fromBlobs distribution.BlobService,
+	toRef reference.Named,
+) (digest.Digest, error) {
+	var options []distribution.ManifestServiceOption
+	if len(tag) > 0 {
+		options = append(options, distribution.WithTag(tag))
+	}
+	return toManifests.Put(ctx, manifest, options...)
+}
@@ -0,0 +1,72 @@
+package append
+
+import "sync"
+
+// Try is used to queue additional work that shares the work queue's
+// concurrency budget.
+type Try interface {
+	// Try queues fn to run on the work queue, blocking only if the queue is
+	// full.
+	Try(fn func() error)
+}
+
+// workQueue runs a bounded number of functions concurrently and collects the
+// first error returned by any of them.
+type workQueue struct {
+	stopCh <-chan struct{}
+	sem    chan struct{}
+
+	wg sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// newWorkQueue creates a work queue that runs at most parallel functions at
+// once. stopCh may be closed to abandon any work that has not yet started.
+func newWorkQueue(parallel int, stopCh <-chan struct{}) *workQueue {
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &workQueue{
+		stopCh: stopCh,
+		sem:    make(chan struct{}, parallel),
+	}
+}
+
+// Try invokes fn with a Try that can be used to queue work against this
+// queue's concurrency budget, then waits for all queued work to complete and
+// returns the first error encountered, if any.
+func (q *workQueue) Try(fn func(w Try)) error {
+	fn(workQueueTry{q})
+	q.wg.Wait()
+	return q.err
+}
+
+// workQueueTry adapts a *workQueue to the Try interface passed into the
+// function given to workQueue.Try.
+type workQueueTry struct {
+	q *workQueue
+}
+
+func (t workQueueTry) Try(fn func() error) {
+	q := t.q
+	q.wg.Add(1)
+	select {
+	case q.sem <- struct{}{}:
+	case <-q.stopCh:
+		q.wg.Done()
+		return
+	}
+	go func() {
+		defer q.wg.Done()
+		defer func() { <-q.sem }()
+		if err := fn(); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+		}
+	}()
+}